@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"secretstrings/drain"
+	"secretstrings/stubs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// policy decides which server in a pool receives the next call.
+type policy int
+
+const (
+	roundRobin policy = iota
+	leastOutstanding
+)
+
+func parsePolicy(s string) (policy, error) {
+	switch s {
+	case "rr", "roundrobin":
+		return roundRobin, nil
+	case "lo", "leastoutstanding":
+		return leastOutstanding, nil
+	}
+	return roundRobin, fmt.Errorf("unknown policy %q", s)
+}
+
+type worker struct {
+	address     string
+	premium     bool
+	client      *rpc.Client
+	outstanding int
+	served      int
+	failed      int
+	removed     bool
+}
+
+// pool is a set of interchangeable servers. All fields are guarded by mutex.
+type pool struct {
+	mutex   sync.Mutex
+	workers []*worker
+	next    int
+	policy  policy
+}
+
+// add puts a server in the pool. A server that registers again, e.g. after reconnecting,
+// replaces its old entry so that it does not receive a double share of the calls.
+func (p *pool) add(w *worker) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, other := range p.workers {
+		if other.address == w.address {
+			p.workers[i] = w
+			other.removed = true
+			if other.outstanding == 0 {
+				other.client.Close()
+			}
+			return len(p.workers)
+		}
+	}
+	p.workers = append(p.workers, w)
+	return len(p.workers)
+}
+
+// remove stops new calls going to the server. Its connection is closed once
+// the calls already forwarded to it have finished.
+func (p *pool) remove(w *worker) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, other := range p.workers {
+		if other == w {
+			p.workers = append(p.workers[:i], p.workers[i+1:]...)
+			break
+		}
+	}
+	w.removed = true
+	if w.outstanding == 0 {
+		w.client.Close()
+	}
+}
+
+func (p *pool) find(address string) *worker {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, w := range p.workers {
+		if w.address == address {
+			return w
+		}
+	}
+	return nil
+}
+
+func (p *pool) size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.workers)
+}
+
+// pick chooses a server according to the pool's policy and marks a call as outstanding on it.
+func (p *pool) pick() (*worker, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.workers) == 0 {
+		return nil, errors.New("no servers available")
+	}
+
+	var chosen *worker
+	switch p.policy {
+	case leastOutstanding:
+		// Ties are broken round-robin so idle servers share the load evenly.
+		for i := range p.workers {
+			w := p.workers[(p.next+i)%len(p.workers)]
+			if chosen == nil || w.outstanding < chosen.outstanding {
+				chosen = w
+			}
+		}
+		p.next = (p.next + 1) % len(p.workers)
+	default:
+		chosen = p.workers[p.next%len(p.workers)]
+		p.next = (p.next + 1) % len(p.workers)
+	}
+	chosen.outstanding++
+	return chosen, nil
+}
+
+func (p *pool) done(w *worker, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	w.outstanding--
+	if err != nil {
+		w.failed++
+	} else {
+		w.served++
+	}
+	if w.removed && w.outstanding == 0 {
+		w.client.Close()
+	}
+}
+
+func (p *pool) stats() []stubs.WorkerStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := make([]stubs.WorkerStats, 0, len(p.workers))
+	for _, w := range p.workers {
+		stats = append(stats, stubs.WorkerStats{
+			Address:     w.address,
+			Premium:     w.premium,
+			Outstanding: w.outstanding,
+			Served:      w.served,
+			Failed:      w.failed,
+		})
+	}
+	return stats
+}
+
+// forward sends the call to a server from the pool. If the connection to that
+// server has died, or it is shutting down, it is dropped from the pool and the
+// call is retried elsewhere. Other errors returned by the server itself are
+// passed straight back to the client.
+func (p *pool) forward(handler string, req interface{}, res interface{}) error {
+	for {
+		w, err := p.pick()
+		if err != nil {
+			return err
+		}
+		err = w.client.Call(handler, req, res)
+		p.done(w, err)
+		if _, ok := err.(rpc.ServerError); (ok && !drain.IsShuttingDown(err)) || err == nil {
+			return err
+		}
+		fmt.Println("Dropping server", w.address+":", err)
+		p.remove(w)
+	}
+}
+
+type Broker struct {
+	standard *pool
+	premium  *pool
+	started  time.Time
+	served   int
+	mutex    sync.Mutex
+	gate     *drain.Gate
+}
+
+func (b *Broker) addWorker(address string, premium bool) (int, error) {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return 0, err
+	}
+	w := &worker{address: address, premium: premium, client: client}
+	if premium {
+		return b.premium.add(w), nil
+	}
+	return b.standard.add(w), nil
+}
+
+// Register is called by servers joining the broker.
+func (b *Broker) Register(req stubs.WorkerRequest, res *stubs.WorkerResponse) (err error) {
+	res.Workers, err = b.addWorker(req.Address, req.Premium)
+	if err != nil {
+		return
+	}
+	fmt.Println("Registered server", req.Address, "premium:", req.Premium)
+	return
+}
+
+// pool returns the servers that handle premium or standard calls.
+func (b *Broker) pool(premium bool) *pool {
+	if premium {
+		return b.premium
+	}
+	return b.standard
+}
+
+// Deregister is called by servers that are shutting down.
+func (b *Broker) Deregister(req stubs.WorkerRequest, res *stubs.WorkerResponse) (err error) {
+	p := b.pool(req.Premium)
+	w := p.find(req.Address)
+	if w == nil {
+		err = fmt.Errorf("Server %v is not registered", req.Address)
+		return
+	}
+	p.remove(w)
+	res.Workers = p.size()
+	fmt.Println("Deregistered server", req.Address, "premium:", req.Premium)
+	return
+}
+
+func (b *Broker) Stats(req stubs.StatsRequest, res *stubs.StatsResponse) (err error) {
+	res.Workers = append(b.standard.stats(), b.premium.stats()...)
+	b.mutex.Lock()
+	res.Served = b.served
+	b.mutex.Unlock()
+	res.Uptime = time.Since(b.started).Seconds()
+	return
+}
+
+func (b *Broker) count() {
+	b.mutex.Lock()
+	b.served++
+	b.mutex.Unlock()
+}
+
+// reportThroughput prints the number of calls served every interval.
+func (b *Broker) reportThroughput(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	last := 0
+	for range ticker.C {
+		b.mutex.Lock()
+		served := b.served
+		b.mutex.Unlock()
+		fmt.Printf("Served %-8v %6.2f calls/sec   servers %v standard, %v premium\n",
+			served, float64(served-last)/interval.Seconds(), b.standard.size(), b.premium.size())
+		last = served
+	}
+}
+
+// SecretStringOperations has the same methods as the real server so that
+// clients can point at the broker without any changes.
+type SecretStringOperations struct {
+	broker *Broker
+}
+
+func (s *SecretStringOperations) Reverse(req stubs.Request, res *stubs.Response) (err error) {
+	if err = s.broker.gate.Enter(); err != nil {
+		return
+	}
+	defer s.broker.gate.Leave()
+	err = s.broker.standard.forward(stubs.ReverseHandler, req, res)
+	s.broker.count()
+	return
+}
+
+func (s *SecretStringOperations) FastReverse(req stubs.Request, res *stubs.Response) (err error) {
+	if err = s.broker.gate.Enter(); err != nil {
+		return
+	}
+	defer s.broker.gate.Leave()
+	err = s.broker.premium.forward(stubs.PremiumReverseHandler, req, res)
+	s.broker.count()
+	return
+}
+
+func (s *SecretStringOperations) ReverseBatch(req stubs.BatchRequest, res *stubs.BatchResponse) (err error) {
+	if err = s.broker.gate.Enter(); err != nil {
+		return
+	}
+	defer s.broker.gate.Leave()
+	err = s.broker.pool(req.Premium).forward(stubs.ReverseBatchHandler, req, res)
+	s.broker.count()
+	return
+}
+
+// ReverseStream is forwarded like any other call. The server calls back to the client
+// directly, and the client drops any result delivered twice because of a retry.
+func (s *SecretStringOperations) ReverseStream(req stubs.StreamRequest, res *stubs.StreamResponse) (err error) {
+	if err = s.broker.gate.Enter(); err != nil {
+		return
+	}
+	defer s.broker.gate.Leave()
+	err = s.broker.pool(req.Premium).forward(stubs.ReverseStreamHandler, req, res)
+	s.broker.count()
+	return
+}
+
+func splitAddresses(s string) []string {
+	var addresses []string
+	for _, address := range strings.Split(s, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func main() {
+	pAddr := flag.String("port", "8040", "Port to listen on")
+	workers := flag.String("workers", "", "Comma separated IP:port list of servers to forward Reverse calls to")
+	premium := flag.String("premium", "", "Comma separated IP:port list of servers to forward FastReverse calls to")
+	policyName := flag.String("policy", "rr", "Load balancing policy: rr (round-robin) or lo (least outstanding)")
+	jsonPort := flag.String("jsonport", "", "Port to also serve JSON-RPC on, for clients not written in Go")
+	drainTimeout := flag.Duration("drain", 30*time.Second, "Time to let in-flight calls finish after SIGTERM")
+	flag.Parse()
+	// Listen for SIGTERM before setting anything up, so that one arriving during
+	// setup still drains once serving has started rather than killing the process.
+	sigterm := drain.Signalled()
+
+	p, err := parsePolicy(*policyName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	broker := &Broker{
+		standard: &pool{policy: p},
+		premium:  &pool{policy: p},
+		started:  time.Now(),
+		gate:     new(drain.Gate),
+	}
+	for _, address := range splitAddresses(*workers) {
+		if _, err := broker.addWorker(address, false); err != nil {
+			fmt.Println("Could not reach server", address+":", err)
+		}
+	}
+	for _, address := range splitAddresses(*premium) {
+		if _, err := broker.addWorker(address, true); err != nil {
+			fmt.Println("Could not reach server", address+":", err)
+		}
+	}
+
+	server := rpc.NewServer()
+	server.Register(broker)
+	server.Register(&SecretStringOperations{broker: broker})
+	listener, err := net.Listen("tcp", ":"+*pAddr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	go broker.reportThroughput(2 * time.Second)
+	go drain.Serve(server, listener)
+	var jsonListener net.Listener
+	if *jsonPort != "" {
+		jsonListener, err = net.Listen("tcp", ":"+*jsonPort)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		go drain.ServeJSON(server, jsonListener)
+	}
+
+	<-sigterm
+	fmt.Println("Shutting down, draining in-flight calls")
+	listener.Close()
+	if jsonListener != nil {
+		jsonListener.Close()
+	}
+	if !broker.gate.Drain(*drainTimeout) {
+		fmt.Println("Gave up waiting for in-flight calls after", *drainTimeout)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"secretstrings/drain"
+	"secretstrings/stubs"
+	"testing"
+	"time"
+)
+
+func reversed(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// backend stands in for a server behind the broker. It reverses instantly and
+// refuses calls once its gate is draining, like the real one.
+type backend struct {
+	gate  *drain.Gate
+	calls int
+}
+
+func (b *backend) ReverseBatch(req stubs.BatchRequest, res *stubs.BatchResponse) (err error) {
+	if err = b.gate.Enter(); err != nil {
+		return
+	}
+	defer b.gate.Leave()
+	b.calls++
+	for _, message := range req.Messages {
+		res.Messages = append(res.Messages, reversed(message))
+		if message == "" {
+			res.Errors = append(res.Errors, "A message must be specified")
+		} else {
+			res.Errors = append(res.Errors, "")
+		}
+	}
+	return
+}
+
+func (b *backend) ReverseStream(req stubs.StreamRequest, res *stubs.StreamResponse) (err error) {
+	if err = b.gate.Enter(); err != nil {
+		return
+	}
+	defer b.gate.Leave()
+	b.calls++
+	client, err := rpc.Dial("tcp", req.Callback)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	for i, message := range req.Messages {
+		result := stubs.StreamResult{Index: i, Message: reversed(message)}
+		if err = client.Call(stubs.ResultHandler, result, new(stubs.StreamAck)); err != nil {
+			return
+		}
+		res.Delivered++
+	}
+	return
+}
+
+// serve serves receiver under name on a loopback port until the test ends.
+func serve(t *testing.T, name string, receiver interface{}) string {
+	server := rpc.NewServer()
+	if err := server.RegisterName(name, receiver); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go drain.Serve(server, listener)
+	return listener.Addr().String()
+}
+
+// startBroker starts a broker forwarding standard calls to the given servers and
+// returns a client connected to it.
+func startBroker(t *testing.T, workers ...string) *rpc.Client {
+	broker := &Broker{
+		standard: &pool{},
+		premium:  &pool{},
+		started:  time.Now(),
+		gate:     new(drain.Gate),
+	}
+	for _, address := range workers {
+		if _, err := broker.addWorker(address, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	address := serve(t, "SecretStringOperations", &SecretStringOperations{broker: broker})
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// startBackends starts a draining server followed by a working one, so that every
+// call reaches the working one only if the broker retries it.
+func startBackends(t *testing.T) (draining, working *backend, addresses []string) {
+	draining = &backend{gate: new(drain.Gate)}
+	draining.gate.Drain(0)
+	working = &backend{gate: new(drain.Gate)}
+	addresses = []string{serve(t, "SecretStringOperations", draining), serve(t, "SecretStringOperations", working)}
+	return
+}
+
+func TestBrokerReverseBatch(t *testing.T) {
+	_, working, addresses := startBackends(t)
+	client := startBroker(t, addresses...)
+
+	request := stubs.BatchRequest{Messages: []string{"hello", "", "wörld"}}
+	response := new(stubs.BatchResponse)
+	if err := client.Call(stubs.ReverseBatchHandler, request, response); err != nil {
+		t.Fatalf("ReverseBatch through the broker failed: %v", err)
+	}
+	if len(response.Messages) != 3 || len(response.Errors) != 3 {
+		t.Fatalf("expected 3 results, got %v messages and %v errors", len(response.Messages), len(response.Errors))
+	}
+	for i, message := range request.Messages {
+		if message == "" {
+			if response.Errors[i] == "" {
+				t.Errorf("expected an error for the empty message")
+			}
+			continue
+		}
+		if response.Errors[i] != "" || response.Messages[i] != reversed(message) {
+			t.Errorf("expected %q for %q, got %q with error %q", reversed(message), message, response.Messages[i], response.Errors[i])
+		}
+	}
+	if working.calls != 1 {
+		t.Errorf("expected the working server to get 1 call, got %v", working.calls)
+	}
+}
+
+// streamResults collects the results streamed back through the broker.
+type streamResults struct {
+	results chan stubs.StreamResult
+}
+
+func (s *streamResults) Deliver(req stubs.StreamResult, res *stubs.StreamAck) error {
+	select {
+	case s.results <- req:
+		return nil
+	default:
+		return errors.New("too many results")
+	}
+}
+
+func TestBrokerReverseStream(t *testing.T) {
+	_, working, addresses := startBackends(t)
+	client := startBroker(t, addresses...)
+
+	messages := []string{"one", "two", "three"}
+	receiver := &streamResults{results: make(chan stubs.StreamResult, len(messages))}
+	callback := serve(t, "Results", receiver)
+
+	request := stubs.StreamRequest{Messages: messages, Callback: callback}
+	response := new(stubs.StreamResponse)
+	if err := client.Call(stubs.ReverseStreamHandler, request, response); err != nil {
+		t.Fatalf("ReverseStream through the broker failed: %v", err)
+	}
+	if response.Delivered != len(messages) {
+		t.Fatalf("expected %v results delivered, got %v", len(messages), response.Delivered)
+	}
+	for range messages {
+		result := <-receiver.results
+		if result.Message != reversed(messages[result.Index]) {
+			t.Errorf("expected %q at %v, got %q", reversed(messages[result.Index]), result.Index, result.Message)
+		}
+	}
+	if working.calls != 1 {
+		t.Errorf("expected the working server to get 1 call, got %v", working.calls)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	address := serve(t, "SecretStringOperations", &backend{gate: new(drain.Gate)})
+	broker := &Broker{standard: &pool{}, premium: &pool{}, started: time.Now(), gate: new(drain.Gate)}
+	request := stubs.WorkerRequest{Address: address}
+	for i := 0; i < 2; i++ {
+		response := new(stubs.WorkerResponse)
+		if err := broker.Register(request, response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Workers != 1 {
+			t.Errorf("expected 1 server after registering %v times, got %v", i+1, response.Workers)
+		}
+	}
+}
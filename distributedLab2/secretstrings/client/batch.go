@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"secretstrings/drain"
+	"secretstrings/stubs"
+	"time"
+)
+
+// sendBatches splits the words into batches of size n sent with ReverseBatch,
+// with at most inFlight batches at once.
+func sendBatches(client *conn, premium bool, words []string, n int, pending []chan result, inFlight int, timeout time.Duration, retries int) {
+	slots := make(chan struct{}, inFlight)
+	for first := 0; first < len(words); first += n {
+		last := first + n
+		if last > len(words) {
+			last = len(words)
+		}
+		slots <- struct{}{}
+		go func(first, last int) {
+			start := time.Now()
+			request := stubs.BatchRequest{Messages: words[first:last], Premium: premium}
+			var response *stubs.BatchResponse
+			attempts, finished, err := retry(func() <-chan error {
+				response = new(stubs.BatchResponse)
+				return client.Go(stubs.ReverseBatchHandler, request, response)
+			}, timeout, retries)
+			latency := time.Since(start)
+			if err == nil && (len(response.Messages) != last-first || len(response.Errors) != last-first) {
+				// A malformed response fails the whole batch rather than guessing which results are missing.
+				err = fmt.Errorf("batch of %v messages got %v results and %v errors", last-first, len(response.Messages), len(response.Errors))
+			}
+
+			for i := first; i < last; i++ {
+				res := result{message: words[i], attempts: attempts, latency: latency, err: err}
+				if err == nil {
+					if response.Errors[i-first] != "" {
+						res.err = rpc.ServerError(response.Errors[i-first])
+					} else {
+						res.response = response.Messages[i-first]
+					}
+				}
+				pending[i] <- res
+			}
+			<-finished
+			<-slots
+		}(first, last)
+	}
+}
+
+// Results receives the results streamed back by the server.
+type Results struct {
+	start   time.Time
+	words   []string
+	pending []chan result
+}
+
+func (r *Results) Deliver(req stubs.StreamResult, res *stubs.StreamAck) (err error) {
+	if req.Index < 0 || req.Index >= len(r.pending) {
+		err = errors.New("Result index out of range")
+		return
+	}
+	delivered := result{message: r.words[req.Index], response: req.Message, attempts: 1, latency: time.Since(r.start)}
+	if req.Error != "" {
+		delivered.err = rpc.ServerError(req.Error)
+	}
+	// A duplicate delivery finds the channel full and is dropped.
+	select {
+	case r.pending[req.Index] <- delivered:
+	default:
+	}
+	return
+}
+
+// sendStream sends all words in a single ReverseStream call. The server calls back
+// to a listener on the callback address with each result as soon as it is ready.
+// The call is never timed out or retried, as results may already have been delivered.
+func sendStream(client *conn, premium bool, words []string, callback string, pending []chan result) error {
+	listener, err := net.Listen("tcp", callback)
+	if err != nil {
+		return err
+	}
+	server := rpc.NewServer()
+	err = server.Register(&Results{start: time.Now(), words: words, pending: pending})
+	if err != nil {
+		return err
+	}
+	go drain.Serve(server, listener)
+
+	request := stubs.StreamRequest{Messages: words, Premium: premium, Callback: listener.Addr().String()}
+	done := client.Go(stubs.ReverseStreamHandler, request, new(stubs.StreamResponse))
+	go func() {
+		callErr := <-done
+		listener.Close()
+		if callErr == nil {
+			return
+		}
+		// Fail every word that never had a result delivered.
+		for i, word := range words {
+			select {
+			case pending[i] <- result{message: word, attempts: 1, err: callErr}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"net/rpc"
+	"secretstrings/drain"
+	"secretstrings/stubs"
+	"testing"
+	"time"
+)
+
+// shortBatches answers every batch with one result too few.
+type shortBatches struct{}
+
+func (shortBatches) ReverseBatch(req stubs.BatchRequest, res *stubs.BatchResponse) error {
+	res.Messages = make([]string, len(req.Messages)-1)
+	res.Errors = make([]string, len(req.Messages)-1)
+	return nil
+}
+
+func TestBatchResultCountMismatch(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("SecretStringOperations", shortBatches{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go drain.Serve(server, listener)
+	client, err := dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	words := []string{"a", "b", "c"}
+	pending := make([]chan result, len(words))
+	for i := range pending {
+		pending[i] = make(chan result, 1)
+	}
+	sendBatches(client, false, words, 2, pending, 1, time.Second, 0)
+	for i := range pending {
+		if res := <-pending[i]; res.err == nil {
+			t.Errorf("expected %q to fail, got %q", words[i], res.response)
+		}
+	}
+}
@@ -2,32 +2,367 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"net/rpc"
 	"os"
 	"secretstrings/stubs"
+	"sort"
+	"sync"
+	"time"
 )
 
-func makeCall(client *rpc.Client, message string) {
-	request := stubs.Request{Message: message}
-	response := new(stubs.Response)
-	client.Call(stubs.ReverseHandler, request, response)
-	fmt.Println("Responded: " + response.Message)
+type result struct {
+	message  string
+	response string
+	err      error
+	attempts int
+	latency  time.Duration
+}
+
+var errTimeout = errors.New("call timed out")
+
+// conn is a connection to the server that is redialled whenever it breaks,
+// so that calls retried after a lost connection are sent on a new one.
+type conn struct {
+	address string
+	mutex   sync.Mutex
+	client  *rpc.Client
+}
+
+func dial(address string) (*conn, error) {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{address: address, client: client}, nil
+}
+
+func (c *conn) get() *rpc.Client {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.client
+}
+
+// redial replaces broken with a new connection, unless another call has already done so.
+// If the server cannot be reached the broken client is kept, so the next attempt fails
+// straight away with rpc.ErrShutdown and dials again.
+func (c *conn) redial(broken *rpc.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client != broken {
+		return
+	}
+	client, err := rpc.Dial("tcp", c.address)
+	if err != nil {
+		return
+	}
+	broken.Close()
+	c.client = client
+}
+
+func (c *conn) Close() error {
+	return c.get().Close()
+}
+
+// Go starts a call and reports its outcome on the returned channel. Any error that was
+// not returned by the server itself means the connection is broken, so it is redialled.
+func (c *conn) Go(handler string, args interface{}, reply interface{}) <-chan error {
+	client := c.get()
+	call := client.Go(handler, args, reply, make(chan *rpc.Call, 1))
+	done := make(chan error, 1)
+	go func() {
+		<-call.Done
+		if _, ok := call.Error.(rpc.ServerError); call.Error != nil && !ok {
+			c.redial(client)
+		}
+		done <- call.Error
+	}()
+	return done
+}
+
+func (c *conn) Call(handler string, args interface{}, reply interface{}) error {
+	return <-c.Go(handler, args, reply)
+}
+
+// sender prepares a call for message. Each attempt at it is started by calling the
+// returned function, which reports its outcome on the returned channel.
+type sender func(message string) func(response *stubs.Response) <-chan error
+
+// direct calls the handler on the server (or load-balancing broker) the client is connected to.
+func direct(c *conn, handler string) sender {
+	return func(message string) func(response *stubs.Response) <-chan error {
+		request := stubs.Request{Message: message}
+		return func(response *stubs.Response) <-chan error {
+			return c.Go(handler, request, response)
+		}
+	}
+}
+
+// published publishes the message as a job on a pub/sub broker topic and collects its result.
+// The job is published once; later attempts only collect it again, waiting at most wait
+// each time, so that retrying does not queue duplicate jobs.
+func published(client *conn, topic string, wait time.Duration) sender {
+	return func(message string) func(response *stubs.Response) <-chan error {
+		var mutex sync.Mutex
+		var id uint64
+		// publish returns the job's ID, publishing it first if no attempt has yet succeeded.
+		publish := func() (uint64, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if id != 0 {
+				return id, nil
+			}
+			payload, err := stubs.Encode(stubs.Request{Message: message})
+			if err != nil {
+				return 0, err
+			}
+			request := stubs.PublishRequest{Topic: topic, Payload: payload}
+			job := new(stubs.PublishResponse)
+			if err := client.Call(stubs.PublishHandler, request, job); err != nil {
+				return 0, err
+			}
+			id = job.ID
+			return id, nil
+		}
+		return func(response *stubs.Response) <-chan error {
+			done := make(chan error, 1)
+			go func() {
+				job, err := publish()
+				if err != nil {
+					done <- err
+					return
+				}
+				result := new(stubs.CollectResponse)
+				if err := client.Call(stubs.CollectHandler, stubs.CollectRequest{ID: job, Wait: wait}, result); err != nil {
+					done <- err
+					return
+				}
+				if !result.Ok {
+					done <- errTimeout
+					return
+				}
+				if result.Error != "" {
+					// Failures reported by the worker are treated like any other server error.
+					done <- rpc.ServerError(result.Error)
+					return
+				}
+				done <- stubs.Decode(result.Payload, response)
+			}()
+			return done
+		}
+	}
+}
+
+// retry starts attempts until one succeeds, retrying those that time out or fail in transit.
+// Errors reported by the server itself are not retried as they would fail again.
+// An attempt that times out keeps running on the server, so finished is closed only
+// once every attempt has returned.
+func retry(attempt func() <-chan error, timeout time.Duration, retries int) (attempts int, finished <-chan struct{}, err error) {
+	var running sync.WaitGroup
+	defer func() {
+		closed := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(closed)
+		}()
+		finished = closed
+	}()
+	for attempts <= retries {
+		attempts++
+		running.Add(1)
+		done := attempt()
+		select {
+		case err = <-done:
+			running.Done()
+		case <-time.After(timeout):
+			err = errTimeout
+			go func() {
+				<-done
+				running.Done()
+			}()
+		}
+		if err == nil {
+			return
+		}
+		if _, ok := err.(rpc.ServerError); ok {
+			return
+		}
+	}
+	return
+}
+
+// makeCall sends a single message. finished is closed once none of its attempts are still running.
+func makeCall(send sender, message string, timeout time.Duration, retries int) (res result, finished <-chan struct{}) {
+	res = result{message: message}
+	start := time.Now()
+	var response *stubs.Response
+	attempt := send(message)
+	res.attempts, finished, res.err = retry(func() <-chan error {
+		response = new(stubs.Response)
+		return attempt(response)
+	}, timeout, retries)
+	if res.err == nil {
+		res.response = response.Message
+	}
+	res.latency = time.Since(start)
+	return
+}
+
+// sendEach sends every word as its own call, with at most inFlight calls at once.
+// A call holds its slot until every attempt at it has returned, timed out or not.
+func sendEach(send sender, words []string, pending []chan result, inFlight int, timeout time.Duration, retries int) {
+	slots := make(chan struct{}, inFlight)
+	for i, word := range words {
+		slots <- struct{}{}
+		go func(i int, word string) {
+			res, finished := makeCall(send, word, timeout, retries)
+			pending[i] <- res
+			<-finished
+			<-slots
+		}(i, word)
+	}
+}
+
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	return words, scanner.Err()
+}
+
+// percentile returns the p-th percentile of already sorted latencies using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func printSummary(results []result, elapsed time.Duration) {
+	var latencies []time.Duration
+	failed, retried := 0, 0
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+		} else {
+			latencies = append(latencies, res.latency)
+		}
+		if res.attempts > 1 {
+			retried++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Println("-----------------")
+	fmt.Printf("%-10v %v in %v (%.2f calls/sec)\n", "Calls", len(results), elapsed.Round(time.Millisecond),
+		float64(len(results))/elapsed.Seconds())
+	fmt.Printf("%-10v %v\n", "Failed", failed)
+	fmt.Printf("%-10v %v\n", "Retried", retried)
+	for _, p := range []float64{50, 90, 99} {
+		fmt.Printf("%-10v %v\n", fmt.Sprintf("p%v", p), percentile(latencies, p).Round(time.Millisecond))
+	}
+	fmt.Printf("%-10v %v\n", "max", percentile(latencies, 100).Round(time.Millisecond))
 }
 
 func main() {
 	server := flag.String("server", "127.0.0.1:8030", "IP:port string to connect to as server")
+	inFlight := flag.Int("inflight", 8, "Maximum number of calls in flight at once, counting attempts that timed out but are still running")
+	timeout := flag.Duration("timeout", 15*time.Second, "Time to wait for a single call before retrying (not with -stream)")
+	retries := flag.Int("retries", 2, "Number of times to retry a failed call (not with -stream)")
+	premium := flag.Bool("premium", false, "Use the premium (fast) reverse handler")
+	wordlist := flag.String("wordlist", "wordlist", "File containing one message per line")
+	pubsub := flag.Bool("pubsub", false, "Treat the server as a pub/sub broker and publish messages as jobs")
+	batch := flag.Int("batch", 0, "Send messages in batches of this size using ReverseBatch")
+	stream := flag.Bool("stream", false, "Send all messages at once and receive results through a callback as they finish")
+	callback := flag.String("callback", "127.0.0.1:0", "IP:port to receive streamed results on")
 	flag.Parse()
-	client, _ := rpc.Dial("tcp", *server)
+
+	if *inFlight < 1 {
+		*inFlight = 1
+	}
+	if *stream {
+		set := false
+		flag.Visit(func(f *flag.Flag) {
+			set = set || f.Name == "timeout" || f.Name == "retries"
+		})
+		if set {
+			fmt.Println("-timeout and -retries cannot be used with -stream")
+			os.Exit(2)
+		}
+	}
+
+	client, err := dial(*server)
+	if err != nil {
+		fmt.Println("Could not connect to server:", err)
+		os.Exit(1)
+	}
 	defer client.Close()
 
-	file, _ := os.Open("wordlist")
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		t := scanner.Text()
-		fmt.Println("Called: " + t)
-		makeCall(client, t)
+	// The broker answers a collection before the attempt times out, so that the
+	// next attempt does not race an earlier one still waiting for the result.
+	wait := *timeout * 3 / 4
+	send := direct(client, stubs.ReverseHandler)
+	switch {
+	case *pubsub && *premium:
+		send = published(client, stubs.PremiumReverseTopic, wait)
+	case *pubsub:
+		send = published(client, stubs.ReverseTopic, wait)
+	case *premium:
+		send = direct(client, stubs.PremiumReverseHandler)
+	}
+
+	words, err := readWords(*wordlist)
+	if err != nil {
+		fmt.Println("Could not read wordlist:", err)
+		os.Exit(1)
 	}
 
+	// Each word gets its own result channel so that responses can be
+	// printed in wordlist order however the calls complete.
+	pending := make([]chan result, len(words))
+	for i := range pending {
+		pending[i] = make(chan result, 1)
+	}
+	start := time.Now()
+	switch {
+	case *stream:
+		if err := sendStream(client, *premium, words, *callback, pending); err != nil {
+			fmt.Println("Could not start streaming:", err)
+			os.Exit(1)
+		}
+	case *batch > 0:
+		go sendBatches(client, *premium, words, *batch, pending, *inFlight, *timeout, *retries)
+	default:
+		go sendEach(send, words, pending, *inFlight, *timeout, *retries)
+	}
+
+	results := make([]result, len(words))
+	for i := range pending {
+		results[i] = <-pending[i]
+		fmt.Println("Called: " + results[i].message)
+		if results[i].err != nil {
+			fmt.Printf("Failed after %v attempts: %v\n", results[i].attempts, results[i].err)
+		} else {
+			fmt.Println("Responded: " + results[i].response)
+		}
+	}
+	printSummary(results, time.Since(start))
 }
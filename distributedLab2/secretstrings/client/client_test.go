@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net"
+	"net/rpc"
+	"secretstrings/stubs"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echo answers Reverse with the message it was sent.
+type echo struct{}
+
+func (echo) Reverse(req stubs.Request, res *stubs.Response) error {
+	res.Message = req.Message
+	return nil
+}
+
+func TestRedialAfterLostConnection(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("SecretStringOperations", echo{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The first connection is dropped straight away, as if the server had restarted.
+	go func() {
+		for first := true; ; first = false {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if first {
+				conn.Close()
+				continue
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	client, err := dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	res, _ := makeCall(direct(client, stubs.ReverseHandler), "hello", time.Second, 2)
+	if res.err != nil {
+		t.Fatalf("expected the call to succeed on a new connection, got %v", res.err)
+	}
+	if res.response != "hello" || res.attempts != 2 {
+		t.Errorf("expected \"hello\" after 2 attempts, got %q after %v", res.response, res.attempts)
+	}
+}
+
+func TestRetryFinishesAfterTimedOutAttempts(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	attempt := func() <-chan error {
+		calls++
+		done := make(chan error, 1)
+		if calls == 1 {
+			// The first attempt outlives its timeout.
+			go func() {
+				<-release
+				done <- nil
+			}()
+		} else {
+			done <- nil
+		}
+		return done
+	}
+
+	attempts, finished, err := retry(attempt, 10*time.Millisecond, 2)
+	if err != nil || attempts != 2 {
+		t.Fatalf("expected success after 2 attempts, got %v after %v", err, attempts)
+	}
+	select {
+	case <-finished:
+		t.Fatal("finished was closed while the timed out attempt was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("finished was not closed after every attempt returned")
+	}
+}
+
+func TestPercentileNearestRank(t *testing.T) {
+	sorted := make([]time.Duration, 80)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{p: 0, expected: 1 * time.Millisecond},
+		{p: 50, expected: 40 * time.Millisecond},
+		{p: 95, expected: 76 * time.Millisecond},
+		{p: 99, expected: 80 * time.Millisecond},
+		{p: 100, expected: 80 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := percentile(sorted, test.p); got != test.expected {
+			t.Errorf("p%v of 80 latencies: expected %v, got %v", test.p, test.expected, got)
+		}
+	}
+}
+
+// slowJobs is a pub/sub broker whose first collection of each job times out.
+type slowJobs struct {
+	mutex     sync.Mutex
+	published int
+	collected int
+}
+
+func (s *slowJobs) Publish(req stubs.PublishRequest, res *stubs.PublishResponse) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.published++
+	res.ID = uint64(s.published)
+	return nil
+}
+
+func (s *slowJobs) Collect(req stubs.CollectRequest, res *stubs.CollectResponse) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.collected++
+	if s.collected == 1 {
+		return
+	}
+	res.Ok = true
+	res.Payload, err = stubs.Encode(stubs.Response{Message: "olleh"})
+	return
+}
+
+func TestRetryCollectsPublishedJob(t *testing.T) {
+	broker := new(slowJobs)
+	server := rpc.NewServer()
+	if err := server.RegisterName("PubSub", broker); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go server.Accept(listener)
+
+	client, err := dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	res, _ := makeCall(published(client, stubs.ReverseTopic, time.Second), "hello", time.Second, 2)
+	if res.err != nil || res.response != "olleh" || res.attempts != 2 {
+		t.Fatalf("expected \"olleh\" after 2 attempts, got %q after %v (%v)", res.response, res.attempts, res.err)
+	}
+	if broker.published != 1 {
+		t.Errorf("expected the job to be published once, got %v", broker.published)
+	}
+}
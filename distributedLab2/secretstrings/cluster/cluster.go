@@ -0,0 +1,236 @@
+// Package cluster runs a broker and several servers as local processes for testing distributed features.
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"secretstrings/stubs"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Config describes the cluster to start. Zero values pick sensible defaults.
+type Config struct {
+	// Dir is the secretstrings source directory the binaries are built from.
+	Dir string
+	// Workers and Premium are the number of standard and premium servers.
+	Workers int
+	Premium int
+	// Policy is passed to the broker's -policy flag.
+	Policy string
+	// Output receives the output of every process. It is discarded if nil.
+	Output io.Writer
+	// Ready is how long to wait for every server to register with the broker.
+	Ready time.Duration
+}
+
+// Cluster is a running broker and its servers.
+type Cluster struct {
+	Broker  string
+	Servers []string
+	bin     string
+	procs   []*exec.Cmd // the broker followed by the servers, nil once stopped
+}
+
+// TB is the part of testing.TB used by StartTB.
+type TB interface {
+	Helper()
+	Fatal(args ...interface{})
+	Cleanup(func())
+}
+
+// StartTB starts a cluster for a test and stops it when the test finishes.
+func StartTB(tb TB, cfg Config) *Cluster {
+	tb.Helper()
+	c, err := Start(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { c.Stop() })
+	return c
+}
+
+// Start builds the broker and server, starts them on free localhost ports and
+// waits until every server has registered with the broker.
+func Start(cfg Config) (*Cluster, error) {
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = "rr"
+	}
+	if cfg.Output == nil {
+		cfg.Output = ioutil.Discard
+	}
+	if cfg.Ready == 0 {
+		cfg.Ready = 10 * time.Second
+	}
+
+	bin, err := ioutil.TempDir("", "secretstrings-cluster")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{bin: bin}
+	for _, name := range []string{"broker", "server"} {
+		if err := Build(cfg.Dir, name, filepath.Join(bin, name)); err != nil {
+			c.Stop()
+			return nil, err
+		}
+	}
+
+	port, err := freePort()
+	if err != nil {
+		c.Stop()
+		return nil, err
+	}
+	c.Broker = "127.0.0.1:" + port
+	if err := c.spawn(cfg.Output, "broker", "-port", port, "-policy", cfg.Policy); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	if err := waitForListener(c.Broker, cfg.Ready); err != nil {
+		c.Stop()
+		return nil, err
+	}
+
+	for i := 0; i < cfg.Workers+cfg.Premium; i++ {
+		port, err := freePort()
+		if err != nil {
+			c.Stop()
+			return nil, err
+		}
+		premium := strconv.FormatBool(i >= cfg.Workers)
+		if err := c.spawn(cfg.Output, "server", "-port", port, "-broker", c.Broker, "-premium="+premium); err != nil {
+			c.Stop()
+			return nil, err
+		}
+		c.Servers = append(c.Servers, "127.0.0.1:"+port)
+	}
+	if err := c.waitForServers(len(c.Servers), cfg.Ready); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Build compiles the command in dir/name to out.
+func Build(dir, name, out string) error {
+	cmd := exec.Command("go", "build", "-o", out, "./"+name)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("building %v: %v\n%s", name, err, output)
+	}
+	return nil
+}
+
+func (c *Cluster) spawn(output io.Writer, name string, args ...string) error {
+	cmd := exec.Command(filepath.Join(c.bin, name), args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.procs = append(c.procs, cmd)
+	return nil
+}
+
+// Stop sends SIGTERM to every process, servers first so that they can deregister,
+// and kills any that have not exited within a few seconds.
+func (c *Cluster) Stop() error {
+	var firstErr error
+	for i := len(c.procs) - 1; i >= 0; i-- {
+		if c.procs[i] == nil {
+			continue
+		}
+		if err := stop(c.procs[i], 5*time.Second); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.procs = nil
+	if c.bin != "" {
+		os.RemoveAll(c.bin)
+	}
+	return firstErr
+}
+
+// StopServer sends SIGTERM to the server at Servers[i] and waits for it to drain and exit,
+// leaving the rest of the cluster running.
+func (c *Cluster) StopServer(i int) error {
+	cmd := c.procs[1+i]
+	if cmd == nil {
+		return fmt.Errorf("server %v has already stopped", c.Servers[i])
+	}
+	c.procs[1+i] = nil
+	return stop(cmd, 5*time.Second)
+}
+
+func stop(cmd *exec.Cmd, timeout time.Duration) error {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-exited
+		return fmt.Errorf("%v did not exit after SIGTERM and was killed", filepath.Base(cmd.Path))
+	}
+}
+
+// freePort asks the OS for a port that is currently unused.
+func freePort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
+
+func waitForListener(address string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%v not listening after %v: %v", address, timeout, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (c *Cluster) waitForServers(n int, timeout time.Duration) error {
+	client, err := rpc.Dial("tcp", c.Broker)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	deadline := time.Now().Add(timeout)
+	for {
+		stats := new(stubs.StatsResponse)
+		if err := client.Call(stubs.StatsHandler, stubs.StatsRequest{}, stats); err != nil {
+			return err
+		}
+		if len(stats.Workers) >= n {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("servers did not register with the broker in time")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"net/rpc"
+	"secretstrings/stubs"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reverseAll sends every message to the broker at once and fails the test for any call that fails.
+func reverseAll(t *testing.T, broker string, messages []string) {
+	client, err := rpc.Dial("tcp", broker)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer client.Close()
+	var wg sync.WaitGroup
+	for _, message := range messages {
+		wg.Add(1)
+		go func(message string) {
+			defer wg.Done()
+			response := new(stubs.Response)
+			if err := client.Call(stubs.PremiumReverseHandler, stubs.Request{Message: message}, response); err != nil {
+				t.Errorf("reversing %q failed: %v", message, err)
+			} else if response.Message != reversed(message) {
+				t.Errorf("expected %q for %q, got %q", reversed(message), message, response.Message)
+			}
+		}(message)
+	}
+	wg.Wait()
+}
+
+func reversed(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+var messages = []string{"one", "two", "three", "four", "five", "six", "seven", "eight"}
+
+func TestCluster(t *testing.T) {
+	c := StartTB(t, Config{Dir: "..", Premium: 2})
+	reverseAll(t, c.Broker, messages)
+}
+
+// TestClusterServerStopped stops a server while calls are running on it. It must finish
+// those calls before exiting, and the broker must send later calls to the other server.
+func TestClusterServerStopped(t *testing.T) {
+	c := StartTB(t, Config{Dir: "..", Premium: 2})
+
+	done := make(chan struct{})
+	go func() {
+		reverseAll(t, c.Broker, messages)
+		close(done)
+	}()
+	waitForOutstanding(t, c.Broker, c.Servers[0])
+	if err := c.StopServer(0); err != nil {
+		t.Errorf("stopping %v: %v", c.Servers[0], err)
+	}
+	<-done
+	reverseAll(t, c.Broker, messages)
+}
+
+// waitForOutstanding waits until the broker has forwarded a call to server that has not yet returned.
+func waitForOutstanding(t *testing.T, broker, server string) {
+	client, err := rpc.Dial("tcp", broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		stats := new(stubs.StatsResponse)
+		if err := client.Call(stubs.StatsHandler, stubs.StatsRequest{}, stats); err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range stats.Workers {
+			if w.Address == server && w.Outstanding > 0 {
+				return
+			}
+		}
+	}
+	t.Fatal("no calls were forwarded to", server)
+}
@@ -0,0 +1,96 @@
+// Package drain lets an RPC server shut down without dropping the calls it is working on.
+package drain
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrShuttingDown is returned for calls that arrive after draining has started.
+// Brokers recognise its message and retry the call on another server.
+var ErrShuttingDown = errors.New("Server is shutting down")
+
+// IsShuttingDown reports whether err, possibly received over RPC, is ErrShuttingDown.
+func IsShuttingDown(err error) bool {
+	return err != nil && err.Error() == ErrShuttingDown.Error()
+}
+
+// Gate counts the calls in flight on a server.
+type Gate struct {
+	mutex    sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+// Enter must be called at the start of every RPC method, followed by a deferred Leave if it succeeds.
+func (g *Gate) Enter() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.draining {
+		return ErrShuttingDown
+	}
+	g.inFlight.Add(1)
+	return nil
+}
+
+func (g *Gate) Leave() {
+	g.inFlight.Done()
+}
+
+// Drain refuses any new calls and waits up to timeout for those in flight to finish.
+// It returns false if some calls were still running when the timeout expired.
+func (g *Gate) Drain(timeout time.Duration) bool {
+	g.mutex.Lock()
+	g.draining = true
+	g.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Serve accepts connections until the listener is closed.
+// Unlike rpc.Accept it returns quietly instead of logging the closed listener.
+func Serve(server *rpc.Server, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// ServeJSON is Serve for clients speaking JSON-RPC 1.0 instead of gob,
+// such as scripts written in other languages.
+func ServeJSON(server *rpc.Server, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// Signalled returns a channel that receives once the process gets SIGTERM or SIGINT.
+func Signalled() <-chan os.Signal {
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGTERM, syscall.SIGINT)
+	return sigterm
+}
@@ -0,0 +1,86 @@
+package drain
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+func TestEnterAfterDrain(t *testing.T) {
+	g := new(Gate)
+	if !g.Drain(time.Second) {
+		t.Fatal("Drain with no calls in flight timed out")
+	}
+	if err := g.Enter(); err != ErrShuttingDown {
+		t.Errorf("expected ErrShuttingDown from Enter after Drain, got %v", err)
+	}
+}
+
+func TestDrainWaitsForInFlight(t *testing.T) {
+	g := new(Gate)
+	if err := g.Enter(); err != nil {
+		t.Fatal(err)
+	}
+	left := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(left)
+		g.Leave()
+	}()
+	if !g.Drain(5 * time.Second) {
+		t.Fatal("Drain timed out although the call finished")
+	}
+	select {
+	case <-left:
+	default:
+		t.Error("Drain returned before the call in flight had finished")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	g := new(Gate)
+	if err := g.Enter(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Leave()
+	if g.Drain(10 * time.Millisecond) {
+		t.Error("Drain returned true with a call still in flight")
+	}
+}
+
+// Refuser behaves like a server that has started draining.
+type Refuser struct{}
+
+func (Refuser) Call(req int, res *int) error {
+	return ErrShuttingDown
+}
+
+func TestIsShuttingDownOverRPC(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.Register(Refuser{}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go Serve(server, listener)
+
+	client, err := rpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	err = client.Call("Refuser.Call", 0, new(int))
+	if _, ok := err.(rpc.ServerError); !ok {
+		t.Fatalf("expected an rpc.ServerError, got %#v", err)
+	}
+	if !IsShuttingDown(err) {
+		t.Errorf("IsShuttingDown(%v) is false", err)
+	}
+	if IsShuttingDown(rpc.ServerError("some other error")) || IsShuttingDown(nil) {
+		t.Error("IsShuttingDown is true for an unrelated error")
+	}
+}
@@ -0,0 +1,62 @@
+""" Calls the secretstrings server (or broker) over JSON-RPC.
+
+Start the server with a JSON-RPC port, e.g.
+    go run ./server -jsonport 8031
+and then run
+    python3 jsonrpc_client.py --server 127.0.0.1:8031
+"""
+
+import argparse
+import itertools
+import json
+import socket
+
+
+class JsonRpcClient:
+    """ Minimal JSON-RPC 1.0 client matching Go's net/rpc/jsonrpc codec. """
+
+    def __init__(self, address):
+        host, port = address.rsplit(':', 1)
+        self.sock = socket.create_connection((host, int(port)))
+        self.reader = self.sock.makefile('r')
+        self.ids = itertools.count()
+
+    def call(self, method, params):
+        request_id = next(self.ids)
+        # Go's codec expects params to be a list holding exactly one object.
+        request = {'method': method, 'params': [params], 'id': request_id}
+        self.sock.sendall(json.dumps(request).encode() + b'\n')
+        response = json.loads(self.reader.readline())
+        if response['id'] != request_id:
+            raise RuntimeError('Response id %s does not match request id %s' % (response['id'], request_id))
+        if response['error'] is not None:
+            raise RuntimeError(response['error'])
+        return response['result']
+
+    def close(self):
+        self.reader.close()
+        self.sock.close()
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument('--server', default='127.0.0.1:8031', help='IP:port of the JSON-RPC port to connect to')
+    parser.add_argument('--wordlist', default='wordlist', help='File containing one message per line')
+    parser.add_argument('--premium', action='store_true', help='Use the premium (fast) reverse handler')
+    args = parser.parse_args()
+
+    method = 'SecretStringOperations.FastReverse' if args.premium else 'SecretStringOperations.Reverse'
+    client = JsonRpcClient(args.server)
+    with open(args.wordlist) as wordlist:
+        for line in wordlist:
+            message = line.rstrip('\n')
+            print('Called: ' + message)
+            try:
+                print('Responded: ' + client.call(method, {'Message': message})['Message'])
+            except RuntimeError as e:
+                print('Failed: %s' % e)
+    client.close()
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"secretstrings/cluster"
+)
+
+// The launcher starts a local cluster, runs a command against it and tears the cluster down.
+// The broker's address is passed to the command in $BROKER. Without a command the client
+// is run against the broker, e.g.
+//
+//	go run ./launcher -workers 4
+//	go run ./launcher -workers 4 -- go test ./...
+func main() {
+	workers := flag.Int("workers", 3, "Number of standard servers to start")
+	premium := flag.Int("premium", 1, "Number of premium servers to start")
+	policy := flag.String("policy", "rr", "Load balancing policy for the broker")
+	verbose := flag.Bool("v", false, "Show the output of the broker and servers")
+	flag.Parse()
+
+	cfg := cluster.Config{Workers: *workers, Premium: *premium, Policy: *policy}
+	if *verbose {
+		cfg.Output = os.Stderr
+	}
+	c, err := cluster.Start(cfg)
+	if err != nil {
+		fmt.Println("Could not start cluster:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Broker", c.Broker, "servers", c.Servers)
+
+	args := flag.Args()
+	bin := ""
+	if len(args) == 0 {
+		bin, err = ioutil.TempDir("", "secretstrings-client")
+		if err == nil {
+			err = cluster.Build(".", "client", filepath.Join(bin, "client"))
+		}
+		if err != nil {
+			fmt.Println(err)
+			c.Stop()
+			os.Exit(1)
+		}
+		args = []string{filepath.Join(bin, "client"), "-server", c.Broker}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "BROKER="+c.Broker)
+	runErr := cmd.Run()
+
+	if bin != "" {
+		os.RemoveAll(bin)
+	}
+	if err := c.Stop(); err != nil {
+		fmt.Println("Stopping cluster:", err)
+	}
+	if runErr != nil {
+		fmt.Println(runErr)
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		os.Exit(1)
+	}
+}
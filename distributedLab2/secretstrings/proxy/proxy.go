@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// phase is a set of faults applied for a while before moving on to the next phase.
+type phase struct {
+	duration  time.Duration
+	latency   time.Duration
+	jitter    time.Duration
+	bandwidth int     // bytes per second in each direction of a connection, 0 for unlimited
+	dropRate  float64 // chance that each chunk of data forwarded cuts its connection
+	partition bool    // data and new connections are held until the phase ends
+	reset     bool    // every open connection is cut when the phase starts
+}
+
+func (p phase) String() string {
+	var faults []string
+	if p.latency > 0 || p.jitter > 0 {
+		faults = append(faults, fmt.Sprintf("latency %v±%v", p.latency, p.jitter))
+	}
+	if p.bandwidth > 0 {
+		faults = append(faults, fmt.Sprintf("bandwidth %vB/s", p.bandwidth))
+	}
+	if p.dropRate > 0 {
+		faults = append(faults, fmt.Sprintf("drop %v/chunk", p.dropRate))
+	}
+	if p.partition {
+		faults = append(faults, "partition")
+	}
+	if p.reset {
+		faults = append(faults, "reset")
+	}
+	if len(faults) == 0 {
+		return "normal"
+	}
+	return strings.Join(faults, ", ")
+}
+
+// parseSchedule reads phases separated by semicolons, each a duration followed by its faults, e.g.
+//
+//	5s normal; 3s partition; 10s latency=200ms jitter=50ms bandwidth=20000; 2s reset drop=0.5
+func parseSchedule(s string) ([]phase, error) {
+	var phases []phase
+	for _, spec := range strings.Split(s, ";") {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			continue
+		}
+		var p phase
+		var err error
+		if p.duration, err = time.ParseDuration(fields[0]); err != nil {
+			return nil, err
+		}
+		for _, field := range fields[1:] {
+			key, value := field, ""
+			if i := strings.Index(field, "="); i >= 0 {
+				key, value = field[:i], field[i+1:]
+			}
+			switch key {
+			case "normal":
+			case "partition":
+				p.partition = true
+			case "reset":
+				p.reset = true
+			case "latency":
+				p.latency, err = time.ParseDuration(value)
+			case "jitter":
+				p.jitter, err = time.ParseDuration(value)
+			case "bandwidth":
+				p.bandwidth, err = strconv.Atoi(value)
+			case "drop":
+				p.dropRate, err = strconv.ParseFloat(value, 64)
+			default:
+				err = fmt.Errorf("unknown fault %q", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		phases = append(phases, p)
+	}
+	if len(phases) == 0 {
+		return nil, errors.New("empty schedule")
+	}
+	return phases, nil
+}
+
+// Proxy forwards every connection it accepts to the target while applying the current phase's faults.
+type Proxy struct {
+	target   string
+	seed     int64
+	mutex    sync.Mutex
+	current  phase
+	accepted int64
+	conns    map[*link]struct{}
+}
+
+// link is a proxied connection.
+type link struct {
+	client, server net.Conn
+	once           sync.Once
+}
+
+func (l *link) close() {
+	l.once.Do(func() {
+		l.client.Close()
+		l.server.Close()
+	})
+}
+
+func NewProxy(target string, seed int64) *Proxy {
+	return &Proxy{
+		target: target,
+		seed:   seed,
+		conns:  make(map[*link]struct{}),
+	}
+}
+
+func (p *Proxy) phase() phase {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.current
+}
+
+func delay(ph phase, random *rand.Rand) time.Duration {
+	if ph.jitter <= 0 {
+		return ph.latency
+	}
+	d := ph.latency + time.Duration(random.Int63n(int64(2*ph.jitter))) - ph.jitter
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (p *Proxy) setPhase(ph phase) {
+	p.mutex.Lock()
+	p.current = ph
+	var cut []*link
+	if ph.reset {
+		for l := range p.conns {
+			cut = append(cut, l)
+		}
+	}
+	p.mutex.Unlock()
+	for _, l := range cut {
+		l.close()
+	}
+}
+
+// run steps through the schedule, starting again from the beginning if repeat is set.
+func (p *Proxy) run(phases []phase, repeat bool) {
+	for {
+		for i, ph := range phases {
+			fmt.Printf("Phase %v for %v: %v\n", i, ph.duration, ph)
+			p.setPhase(ph)
+			time.Sleep(ph.duration)
+		}
+		if !repeat {
+			fmt.Println("Schedule finished, no faults from now on")
+			p.setPhase(phase{})
+			return
+		}
+	}
+}
+
+// awaitHealed blocks while the network is partitioned.
+func (p *Proxy) awaitHealed() {
+	for p.phase().partition {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (p *Proxy) serve(listener net.Listener) error {
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go p.handle(client)
+	}
+}
+
+func (p *Proxy) handle(client net.Conn) {
+	p.awaitHealed()
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		fmt.Println("Could not reach target:", err)
+		client.Close()
+		return
+	}
+	l := &link{client: client, server: server}
+	p.mutex.Lock()
+	p.conns[l] = struct{}{}
+	n := p.accepted
+	p.accepted++
+	p.mutex.Unlock()
+
+	// Each direction of each connection has its own source, seeded from the proxy's seed
+	// and the order connections were accepted in. The faults a connection sees then do not
+	// depend on how its traffic interleaves with other connections'.
+	upstream := rand.New(rand.NewSource(p.seed + 2*n))
+	downstream := rand.New(rand.NewSource(p.seed + 2*n + 1))
+	finished := make(chan struct{}, 2)
+	go func() { p.pipe(server, client, l, upstream); finished <- struct{}{} }()
+	go func() { p.pipe(client, server, l, downstream); finished <- struct{}{} }()
+	<-finished
+	l.close()
+	<-finished
+
+	p.mutex.Lock()
+	delete(p.conns, l)
+	p.mutex.Unlock()
+}
+
+type chunk struct {
+	data      []byte
+	deliverAt time.Time
+	drop      bool // cut the connection instead of delivering the chunk
+}
+
+// pipe copies src to dst. Reading and writing are split so that latency delays
+// data without limiting throughput, and chunks stay in order. Every random choice
+// is made by the reader, in order, from random.
+func (p *Proxy) pipe(dst io.Writer, src io.Reader, l *link, random *rand.Rand) {
+	chunks := make(chan chunk, 1024)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := src.Read(buf)
+			if n > 0 {
+				ph := p.phase()
+				c := chunk{data: buf[:n], deliverAt: time.Now().Add(delay(ph, random))}
+				c.drop = ph.dropRate > 0 && random.Float64() < ph.dropRate
+				chunks <- c
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for c := range chunks {
+		time.Sleep(time.Until(c.deliverAt))
+		p.awaitHealed()
+		if c.drop {
+			fmt.Println("Dropping connection from", l.client.RemoteAddr())
+			l.close()
+			for range chunks {
+			}
+			return
+		}
+		if bandwidth := p.phase().bandwidth; bandwidth > 0 {
+			time.Sleep(time.Duration(len(c.data)) * time.Second / time.Duration(bandwidth))
+		}
+		if _, err := dst.Write(c.data); err != nil {
+			// Closing the link stops the reader so that chunks is closed.
+			l.close()
+			for range chunks {
+			}
+			return
+		}
+	}
+}
+
+func main() {
+	listen := flag.String("listen", ":9030", "Address to accept connections on")
+	target := flag.String("target", "127.0.0.1:8030", "IP:port to forward connections to")
+	latency := flag.Duration("latency", 0, "Delay added to data in each direction")
+	jitter := flag.Duration("jitter", 0, "Random variation added to the latency")
+	bandwidth := flag.Int("bandwidth", 0, "Bytes per second allowed in each direction of a connection, 0 for unlimited")
+	drop := flag.Float64("drop", 0, "Chance that each chunk of data forwarded cuts its connection")
+	schedule := flag.String("schedule", "", "Phases of faults to step through, overriding the fault flags, e.g. \"5s normal; 3s partition; 5s latency=200ms\"")
+	repeat := flag.Bool("repeat", false, "Loop the schedule forever")
+	seed := flag.Int64("seed", 1, "Seed for jitter and drops, so that runs sending the same data over connections opened in the same order can be reproduced")
+	flag.Parse()
+
+	phases := []phase{{latency: *latency, jitter: *jitter, bandwidth: *bandwidth, dropRate: *drop}}
+	if *schedule != "" {
+		var err error
+		if phases, err = parseSchedule(*schedule); err != nil {
+			fmt.Println("Invalid schedule:", err)
+			os.Exit(1)
+		}
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer listener.Close()
+
+	proxy := NewProxy(*target, *seed)
+	if *schedule != "" {
+		go proxy.run(phases, *repeat)
+	} else {
+		proxy.setPhase(phases[0])
+		fmt.Println("Faults:", phases[0])
+	}
+	fmt.Println("Proxying", *listen, "to", *target)
+	fmt.Println(proxy.serve(listener))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		expected []phase
+		invalid  bool
+	}{
+		{schedule: "5s normal", expected: []phase{{duration: 5 * time.Second}}},
+		{schedule: "5s normal; 3s partition;", expected: []phase{
+			{duration: 5 * time.Second},
+			{duration: 3 * time.Second, partition: true},
+		}},
+		{schedule: "10s latency=200ms jitter=50ms bandwidth=20000", expected: []phase{
+			{duration: 10 * time.Second, latency: 200 * time.Millisecond, jitter: 50 * time.Millisecond, bandwidth: 20000},
+		}},
+		{schedule: "2s reset drop=0.5", expected: []phase{{duration: 2 * time.Second, reset: true, dropRate: 0.5}}},
+		{schedule: "", invalid: true},
+		{schedule: " ; ", invalid: true},
+		{schedule: "soon partition", invalid: true},
+		{schedule: "5s flood", invalid: true},
+		{schedule: "5s latency=fast", invalid: true},
+		{schedule: "5s bandwidth=lots", invalid: true},
+		{schedule: "5s drop=often", invalid: true},
+	}
+	for _, test := range tests {
+		phases, err := parseSchedule(test.schedule)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("expected %q to be rejected, got %v", test.schedule, phases)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.schedule, err)
+		} else if !reflect.DeepEqual(phases, test.expected) {
+			t.Errorf("%q: expected %+v, got %+v", test.schedule, test.expected, phases)
+		}
+	}
+}
+
+// startProxy starts a proxy in front of a server that echoes every line back.
+func startProxy(t *testing.T, seed int64) (*Proxy, string) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { echo.Close() })
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	proxy := NewProxy(echo.Addr().String(), seed)
+	go proxy.serve(listener)
+	return proxy, listener.Addr().String()
+}
+
+// roundTrip sends a line through the proxy and waits up to timeout for it to come back.
+func roundTrip(conn net.Conn, reader *bufio.Reader, timeout time.Duration) error {
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		return err
+	}
+	_, err := reader.ReadString('\n')
+	return err
+}
+
+func dialProxy(t *testing.T, address string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+func TestProxyLatency(t *testing.T) {
+	proxy, address := startProxy(t, 1)
+	proxy.setPhase(phase{latency: 50 * time.Millisecond})
+	conn, reader := dialProxy(t, address)
+	start := time.Now()
+	if err := roundTrip(conn, reader, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The latency is added in each direction.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("round trip took %v, expected at least 100ms", elapsed)
+	}
+}
+
+func TestProxyPartition(t *testing.T) {
+	proxy, address := startProxy(t, 1)
+	proxy.setPhase(phase{partition: true})
+	conn, reader := dialProxy(t, address)
+	if err := roundTrip(conn, reader, 100*time.Millisecond); err == nil {
+		t.Fatal("data got through a partition")
+	}
+
+	proxy.setPhase(phase{})
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("data held by the partition was not delivered once it healed: %v", err)
+	}
+}
+
+func TestProxyReset(t *testing.T) {
+	proxy, address := startProxy(t, 1)
+	conn, reader := dialProxy(t, address)
+	if err := roundTrip(conn, reader, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	proxy.setPhase(phase{reset: true})
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := reader.ReadString('\n'); err != io.EOF {
+		t.Errorf("expected the connection to be closed by the reset, got %v", err)
+	}
+}
+
+// TestProxySeed checks that the same seed cuts connections after the same number of round trips.
+func TestProxySeed(t *testing.T) {
+	survived := func() []int {
+		proxy, address := startProxy(t, 42)
+		proxy.setPhase(phase{dropRate: 0.2})
+		var counts []int
+		for i := 0; i < 5; i++ {
+			conn, reader := dialProxy(t, address)
+			n := 0
+			for ; n < 100 && roundTrip(conn, reader, 5*time.Second) == nil; n++ {
+			}
+			counts = append(counts, n)
+		}
+		return counts
+	}
+	first, second := survived(), survived()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("connections survived %v round trips, then %v with the same seed", first, second)
+	}
+}
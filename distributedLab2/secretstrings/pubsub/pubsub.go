@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"secretstrings/stubs"
+	"sync"
+	"time"
+)
+
+type job struct {
+	stubs.Job
+	completed bool
+	claimed   bool
+	withdrawn bool
+	done      chan struct{}
+	result    stubs.CollectResponse
+	lease     *time.Timer
+	expiry    *time.Timer
+}
+
+// PubSub queues jobs per topic until a worker pulls them and keeps every job
+// until its producer has collected the result, or for ttl after it was completed
+// if the producer never does.
+type PubSub struct {
+	mutex    sync.Mutex
+	topics   map[string]chan *job
+	jobs     map[uint64]*job
+	nextID   uint64
+	lease    time.Duration
+	ttl      time.Duration
+	queueLen int
+}
+
+func NewPubSub(lease, ttl time.Duration, queueLen int) *PubSub {
+	return &PubSub{
+		topics:   make(map[string]chan *job),
+		jobs:     make(map[uint64]*job),
+		lease:    lease,
+		ttl:      ttl,
+		queueLen: queueLen,
+	}
+}
+
+// topic returns the queue for a topic, creating it the first time it is used by either side.
+func (ps *PubSub) topic(name string) chan *job {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	queue, ok := ps.topics[name]
+	if !ok {
+		queue = make(chan *job, ps.queueLen)
+		ps.topics[name] = queue
+	}
+	return queue
+}
+
+func (ps *PubSub) Publish(req stubs.PublishRequest, res *stubs.PublishResponse) (err error) {
+	if req.Topic == "" {
+		err = errors.New("A topic must be specified")
+		return
+	}
+	ps.mutex.Lock()
+	ps.nextID++
+	j := &job{
+		Job:  stubs.Job{ID: ps.nextID, Topic: req.Topic, Payload: req.Payload},
+		done: make(chan struct{}),
+	}
+	ps.jobs[j.ID] = j
+	ps.mutex.Unlock()
+
+	// Blocks once the queue is full, which pushes back on producers.
+	ps.topic(req.Topic) <- j
+	res.ID = j.ID
+	return
+}
+
+// Collect blocks until the job has been completed and then forgets about it.
+// If req.Wait is set it gives up after that long: a job that no worker has taken
+// by then is withdrawn, while one that is being worked on is kept so that the
+// producer can collect it again.
+func (ps *PubSub) Collect(req stubs.CollectRequest, res *stubs.CollectResponse) (err error) {
+	ps.mutex.Lock()
+	j, ok := ps.jobs[req.ID]
+	ps.mutex.Unlock()
+	if !ok {
+		err = fmt.Errorf("Unknown job %v", req.ID)
+		return
+	}
+	if req.Wait > 0 {
+		timeout := time.NewTimer(req.Wait)
+		defer timeout.Stop()
+		select {
+		case <-j.done:
+		case <-timeout.C:
+			if ps.withdraw(j) {
+				err = fmt.Errorf("Job %v was withdrawn as no worker took it within %v", j.ID, req.Wait)
+			}
+			return
+		}
+	} else {
+		<-j.done
+	}
+	ps.mutex.Lock()
+	j.expiry.Stop()
+	delete(ps.jobs, req.ID)
+	ps.mutex.Unlock()
+	*res = j.result
+	res.Ok = true
+	return
+}
+
+// withdraw forgets a job that no worker has taken, reporting whether it did.
+func (ps *PubSub) withdraw(j *job) bool {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if j.completed || j.claimed || ps.jobs[j.ID] != j {
+		return false
+	}
+	j.withdrawn = true
+	delete(ps.jobs, j.ID)
+	return true
+}
+
+func (ps *PubSub) Pull(req stubs.PullRequest, res *stubs.PullResponse) (err error) {
+	timeout := time.NewTimer(req.Wait)
+	defer timeout.Stop()
+	for {
+		select {
+		case j := <-ps.topic(req.Topic):
+			ps.mutex.Lock()
+			if j.completed || j.withdrawn {
+				// A requeued job was finished by its original worker in the meantime,
+				// or its producer gave up on it.
+				ps.mutex.Unlock()
+				continue
+			}
+			j.claimed = true
+			j.lease = time.AfterFunc(ps.lease, func() { ps.requeue(j) })
+			ps.mutex.Unlock()
+			res.Ok = true
+			res.Job = j.Job
+			res.Lease = ps.lease
+			return
+		case <-timeout.C:
+			return
+		}
+	}
+}
+
+// requeue puts a job back on its topic when the worker holding it did not complete it in time.
+func (ps *PubSub) requeue(j *job) {
+	ps.mutex.Lock()
+	requeue := !j.completed && !j.withdrawn
+	j.claimed = false
+	ps.mutex.Unlock()
+	if requeue {
+		fmt.Println("Lease expired, requeueing job", j.ID, "on", j.Topic)
+		ps.topic(j.Topic) <- j
+	}
+}
+
+func (ps *PubSub) Complete(req stubs.CompleteRequest, res *stubs.CompleteResponse) (err error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	j, ok := ps.jobs[req.ID]
+	if !ok {
+		err = fmt.Errorf("Unknown job %v", req.ID)
+		return
+	}
+	if j.completed {
+		return
+	}
+	if j.lease != nil {
+		j.lease.Stop()
+	}
+	j.completed = true
+	j.result = stubs.CollectResponse{Payload: req.Payload, Error: req.Error}
+	j.expiry = time.AfterFunc(ps.ttl, func() { ps.expire(j) })
+	close(j.done)
+	return
+}
+
+// expire forgets a completed job whose producer has not collected it in time.
+func (ps *PubSub) expire(j *job) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if ps.jobs[j.ID] == j {
+		fmt.Println("Result of job", j.ID, "was not collected, forgetting it")
+		delete(ps.jobs, j.ID)
+	}
+}
+
+func main() {
+	pAddr := flag.String("port", "8050", "Port to listen on")
+	lease := flag.Duration("lease", 30*time.Second, "Time a worker has to complete a job before it is handed to another worker")
+	ttl := flag.Duration("ttl", 10*time.Minute, "Time a completed job's result is kept for its producer to collect")
+	queueLen := flag.Int("queue", 1024, "Maximum number of jobs waiting on each topic")
+	flag.Parse()
+
+	rpc.Register(NewPubSub(*lease, *ttl, *queueLen))
+	listener, err := net.Listen("tcp", ":"+*pAddr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer listener.Close()
+	rpc.Accept(listener)
+}
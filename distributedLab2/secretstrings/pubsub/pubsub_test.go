@@ -0,0 +1,110 @@
+package main
+
+import (
+	"secretstrings/stubs"
+	"testing"
+	"time"
+)
+
+// completeJob publishes a job and has a worker pull and complete it, returning its ID.
+func completeJob(t *testing.T, ps *PubSub) uint64 {
+	published := new(stubs.PublishResponse)
+	if err := ps.Publish(stubs.PublishRequest{Topic: "test", Payload: []byte("job")}, published); err != nil {
+		t.Fatal(err)
+	}
+	pulled := new(stubs.PullResponse)
+	if err := ps.Pull(stubs.PullRequest{Topic: "test", Wait: time.Second}, pulled); err != nil || !pulled.Ok {
+		t.Fatalf("could not pull the job: %v", err)
+	}
+	request := stubs.CompleteRequest{ID: pulled.Job.ID, Payload: []byte("result")}
+	if err := ps.Complete(request, new(stubs.CompleteResponse)); err != nil {
+		t.Fatal(err)
+	}
+	return published.ID
+}
+
+func jobCount(ps *PubSub) int {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	return len(ps.jobs)
+}
+
+func TestCollectForgetsJob(t *testing.T) {
+	ps := NewPubSub(time.Minute, time.Minute, 10)
+	id := completeJob(t, ps)
+	result := new(stubs.CollectResponse)
+	if err := ps.Collect(stubs.CollectRequest{ID: id}, result); err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Payload) != "result" {
+		t.Errorf("expected the result \"result\", got %q", result.Payload)
+	}
+	if n := jobCount(ps); n != 0 {
+		t.Errorf("expected no jobs to be kept after collection, got %v", n)
+	}
+	if err := ps.Collect(stubs.CollectRequest{ID: id}, result); err == nil {
+		t.Error("collecting a job twice succeeded")
+	}
+}
+
+func TestUncollectedJobExpires(t *testing.T) {
+	ps := NewPubSub(time.Minute, 50*time.Millisecond, 10)
+	id := completeJob(t, ps)
+	if n := jobCount(ps); n != 1 {
+		t.Fatalf("expected the completed job to be kept until it expires, got %v jobs", n)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for jobCount(ps) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("uncollected job was never forgotten")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := ps.Collect(stubs.CollectRequest{ID: id}, new(stubs.CollectResponse)); err == nil {
+		t.Error("collecting an expired job succeeded")
+	}
+}
+
+func TestCollectWithdrawsUnclaimedJob(t *testing.T) {
+	ps := NewPubSub(time.Minute, time.Minute, 10)
+	published := new(stubs.PublishResponse)
+	if err := ps.Publish(stubs.PublishRequest{Topic: "test", Payload: []byte("job")}, published); err != nil {
+		t.Fatal(err)
+	}
+	request := stubs.CollectRequest{ID: published.ID, Wait: 10 * time.Millisecond}
+	if err := ps.Collect(request, new(stubs.CollectResponse)); err == nil {
+		t.Fatal("collecting a job no worker took succeeded")
+	}
+	if n := jobCount(ps); n != 0 {
+		t.Errorf("expected the job to be withdrawn, got %v jobs", n)
+	}
+	pulled := new(stubs.PullResponse)
+	if err := ps.Pull(stubs.PullRequest{Topic: "test", Wait: 10 * time.Millisecond}, pulled); err != nil || pulled.Ok {
+		t.Errorf("a worker pulled the withdrawn job %v", pulled.Job.ID)
+	}
+}
+
+func TestCollectKeepsClaimedJob(t *testing.T) {
+	ps := NewPubSub(time.Minute, time.Minute, 10)
+	published := new(stubs.PublishResponse)
+	if err := ps.Publish(stubs.PublishRequest{Topic: "test", Payload: []byte("job")}, published); err != nil {
+		t.Fatal(err)
+	}
+	pulled := new(stubs.PullResponse)
+	if err := ps.Pull(stubs.PullRequest{Topic: "test", Wait: time.Second}, pulled); err != nil || !pulled.Ok {
+		t.Fatalf("could not pull the job: %v", err)
+	}
+	request := stubs.CollectRequest{ID: published.ID, Wait: 10 * time.Millisecond}
+	result := new(stubs.CollectResponse)
+	if err := ps.Collect(request, result); err != nil || result.Ok {
+		t.Fatalf("expected the collection to time out, got %+v (%v)", result, err)
+	}
+
+	complete := stubs.CompleteRequest{ID: pulled.Job.ID, Payload: []byte("result")}
+	if err := ps.Complete(complete, new(stubs.CompleteResponse)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Collect(request, result); err != nil || !result.Ok || string(result.Payload) != "result" {
+		t.Errorf("expected the result \"result\" on collecting again, got %+v (%v)", result, err)
+	}
+}
@@ -5,7 +5,9 @@ import ("errors"
 	"net"
 	"time"
 	"math/rand"
+	"secretstrings/drain"
 	"secretstrings/stubs"
+	"sync"
 	"net/rpc")
 
 /** Super-Secret `reversing a string' method we can't allow clients to see. **/
@@ -18,36 +20,185 @@ func ReverseString(s string, i int) string {
     return string(runes)
 }
 
-type SecretStringOperations struct {}
+// SecretStringOperations passes every call through its gate so that the server can drain before exiting.
+type SecretStringOperations struct {
+	gate *drain.Gate
+}
 
-func (s *SecretStringOperations) Reverse(req stubs.Request, res *stubs.Response) (err error) {
+func reverse(req stubs.Request, res *stubs.Response, premium bool) (err error) {
 	if req.Message == "" {
 		err = errors.New("A message must be specified")
 		return
 	}
 
+	if premium {
+		res.Message = ReverseString(req.Message, 2)
+		return
+	}
 	fmt.Println("Got Message: "+req.Message)
 	res.Message = ReverseString(req.Message, 10)
 	return
 }
 
+func (s *SecretStringOperations) Reverse(req stubs.Request, res *stubs.Response) (err error) {
+	if err = s.gate.Enter(); err != nil {
+		return
+	}
+	defer s.gate.Leave()
+	return reverse(req, res, false)
+}
+
 func (s *SecretStringOperations) FastReverse(req stubs.Request, res *stubs.Response) (err error) {
-	if req.Message == "" {
-		err = errors.New("A message must be specified")
+	if err = s.gate.Enter(); err != nil {
+		return
+	}
+	defer s.gate.Leave()
+	return reverse(req, res, true)
+}
+
+// reverseAll reverses every message concurrently and hands each result to deliver as soon as it is ready.
+func reverseAll(messages []string, premium bool, deliver func(i int, message string, err error)) {
+	var wg sync.WaitGroup
+	for i, message := range messages {
+		wg.Add(1)
+		go func(i int, message string) {
+			defer wg.Done()
+			res := new(stubs.Response)
+			err := reverse(stubs.Request{Message: message}, res, premium)
+			deliver(i, res.Message, err)
+		}(i, message)
+	}
+	wg.Wait()
+}
+
+func (s *SecretStringOperations) ReverseBatch(req stubs.BatchRequest, res *stubs.BatchResponse) (err error) {
+	if err = s.gate.Enter(); err != nil {
+		return
+	}
+	defer s.gate.Leave()
+	res.Messages = make([]string, len(req.Messages))
+	res.Errors = make([]string, len(req.Messages))
+	reverseAll(req.Messages, req.Premium, func(i int, message string, err error) {
+		res.Messages[i] = message
+		if err != nil {
+			res.Errors[i] = err.Error()
+		}
+	})
+	return
+}
+
+// ReverseStream calls back to the client with each result as it finishes and
+// returns once all of them have been delivered.
+func (s *SecretStringOperations) ReverseStream(req stubs.StreamRequest, res *stubs.StreamResponse) (err error) {
+	if err = s.gate.Enter(); err != nil {
+		return
+	}
+	defer s.gate.Leave()
+	client, err := rpc.Dial("tcp", req.Callback)
+	if err != nil {
 		return
 	}
+	defer client.Close()
 
-	res.Message = ReverseString(req.Message, 2)
+	var mutex sync.Mutex
+	reverseAll(req.Messages, req.Premium, func(i int, message string, callErr error) {
+		result := stubs.StreamResult{Index: i, Message: message}
+		if callErr != nil {
+			result.Error = callErr.Error()
+		}
+		deliverErr := client.Call(stubs.ResultHandler, result, new(stubs.StreamAck))
+		mutex.Lock()
+		defer mutex.Unlock()
+		if deliverErr != nil {
+			err = deliverErr
+		} else {
+			res.Delivered++
+		}
+	})
 	return
 }
 
+// register announces this server to the broker so that it starts receiving calls.
+func register(broker, address string, premium bool) error {
+	return callBroker(broker, stubs.RegisterHandler, address, premium)
+}
+
+// deregister asks the broker to stop sending calls to this server.
+func deregister(broker, address string, premium bool) error {
+	return callBroker(broker, stubs.DeregisterHandler, address, premium)
+}
+
+func callBroker(broker, handler, address string, premium bool) error {
+	client, err := rpc.Dial("tcp", broker)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := stubs.WorkerRequest{Address: address, Premium: premium}
+	response := new(stubs.WorkerResponse)
+	return client.Call(handler, request, response)
+}
 
 func main(){
 	pAddr := flag.String("port","8030","Port to listen on")
+	brokerAddr := flag.String("broker", "", "IP:port of a broker to register with")
+	ip := flag.String("ip", "127.0.0.1", "IP the broker should use to reach this server")
+	premium := flag.Bool("premium", false, "Register with the broker's premium pool")
+	pubsub := flag.String("pubsub", "", "IP:port of a pub/sub broker to pull jobs from")
+	subscribers := flag.Int("subscribers", 4, "Number of jobs to work on at once when using a pub/sub broker")
+	jsonPort := flag.String("jsonport", "", "Port to also serve JSON-RPC on, for clients not written in Go")
+	drainTimeout := flag.Duration("drain", 30*time.Second, "Time to let in-flight calls finish after SIGTERM")
 	flag.Parse()
+	// Listen for SIGTERM before setting anything up, so that one arriving during
+	// setup still drains once serving has started rather than killing the process.
+	sigterm := drain.Signalled()
 	rand.Seed(time.Now().UnixNano())
-	rpc.Register(&SecretStringOperations{})
-	listener, _ := net.Listen("tcp", ":"+*pAddr)
-	defer listener.Close()
-	rpc.Accept(listener)
+	operations := &SecretStringOperations{gate: new(drain.Gate)}
+	server := rpc.NewServer()
+	server.Register(operations)
+	listener, err := net.Listen("tcp", ":"+*pAddr)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	address := *ip + ":" + *pAddr
+	if *brokerAddr != "" {
+		if err := register(*brokerAddr, address, *premium); err != nil {
+			fmt.Println("Could not register with broker:", err)
+			return
+		}
+	}
+	stopSubscribers := make(chan struct{})
+	if *pubsub != "" {
+		if err := startSubscribers(*pubsub, *subscribers, *premium, operations, stopSubscribers); err != nil {
+			fmt.Println("Could not subscribe to pub/sub broker:", err)
+			return
+		}
+	}
+	go drain.Serve(server, listener)
+	var jsonListener net.Listener
+	if *jsonPort != "" {
+		jsonListener, err = net.Listen("tcp", ":"+*jsonPort)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		go drain.ServeJSON(server, jsonListener)
+	}
+
+	<-sigterm
+	fmt.Println("Shutting down, draining in-flight calls")
+	if *brokerAddr != "" {
+		if err := deregister(*brokerAddr, address, *premium); err != nil {
+			fmt.Println("Could not deregister from broker:", err)
+		}
+	}
+	close(stopSubscribers)
+	listener.Close()
+	if jsonListener != nil {
+		jsonListener.Close()
+	}
+	if !operations.gate.Drain(*drainTimeout) {
+		fmt.Println("Gave up waiting for in-flight calls after", *drainTimeout)
+	}
 }
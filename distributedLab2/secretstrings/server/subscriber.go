@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/rpc"
+	"secretstrings/drain"
+	"secretstrings/stubs"
+	"time"
+)
+
+// operation is the signature shared by the SecretStringOperations methods.
+type operation func(req stubs.Request, res *stubs.Response) error
+
+// runJob decodes a job, runs the operation on it and encodes the result for the broker.
+func runJob(op operation, job stubs.Job) stubs.CompleteRequest {
+	complete := stubs.CompleteRequest{ID: job.ID}
+	var req stubs.Request
+	if err := stubs.Decode(job.Payload, &req); err != nil {
+		complete.Error = err.Error()
+		return complete
+	}
+	var res stubs.Response
+	if err := op(req, &res); err != nil {
+		complete.Error = err.Error()
+		return complete
+	}
+	payload, err := stubs.Encode(res)
+	if err != nil {
+		complete.Error = err.Error()
+		return complete
+	}
+	complete.Payload = payload
+	return complete
+}
+
+// subscribe pulls jobs from a topic on the pub/sub broker one at a time until
+// the connection fails or stop is closed. Each job holds the gate until its
+// result has reached the broker so that draining waits for it.
+func subscribe(client *rpc.Client, topic string, op operation, gate *drain.Gate, stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return drain.ErrShuttingDown
+		default:
+		}
+		pull := stubs.PullRequest{Topic: topic, Wait: 5 * time.Second}
+		pulled := new(stubs.PullResponse)
+		if err := client.Call(stubs.PullHandler, pull, pulled); err != nil {
+			return err
+		}
+		if !pulled.Ok {
+			continue
+		}
+		if err := gate.Enter(); err != nil {
+			// Leave the job uncompleted so the broker hands it to another worker once its lease expires.
+			return err
+		}
+		complete := runJob(op, pulled.Job)
+		err := client.Call(stubs.CompleteHandler, complete, new(stubs.CompleteResponse))
+		gate.Leave()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startSubscribers runs n concurrent subscribers on the topic matching this server's tier.
+func startSubscribers(pubsub string, n int, premium bool, s *SecretStringOperations, stop <-chan struct{}) error {
+	client, err := rpc.Dial("tcp", pubsub)
+	if err != nil {
+		return err
+	}
+	topic := stubs.ReverseTopic
+	if premium {
+		topic = stubs.PremiumReverseTopic
+	}
+	op := func(req stubs.Request, res *stubs.Response) error {
+		return reverse(req, res, premium)
+	}
+	for i := 0; i < n; i++ {
+		go func() {
+			err := subscribe(client, topic, op, s.gate, stop)
+			fmt.Println("Stopped subscribing to", topic+":", err)
+		}()
+	}
+	return nil
+}
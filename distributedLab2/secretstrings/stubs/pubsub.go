@@ -0,0 +1,85 @@
+package stubs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// Handlers exposed by the pub/sub broker.
+// Producers Publish a job onto a topic and Collect its result later using the returned ID.
+// Workers Pull jobs from the topics they subscribe to and Complete them with a result.
+var PublishHandler = "PubSub.Publish"
+var CollectHandler = "PubSub.Collect"
+var PullHandler = "PubSub.Pull"
+var CompleteHandler = "PubSub.Complete"
+
+// Topics used by the secretstrings servers. Jobs on both carry a gob encoded Request
+// and their results a gob encoded Response.
+var ReverseTopic = "reverse"
+var PremiumReverseTopic = "reverse.premium"
+
+// Job is a unit of work queued on a topic. The payload is opaque to the broker,
+// so any gob encodable type can be distributed this way.
+type Job struct {
+	ID      uint64
+	Topic   string
+	Payload []byte
+}
+
+type PublishRequest struct {
+	Topic   string
+	Payload []byte
+}
+
+type PublishResponse struct {
+	ID uint64
+}
+
+// CollectRequest waits for the result of a job, for at most Wait if it is set.
+type CollectRequest struct {
+	ID   uint64
+	Wait time.Duration
+}
+
+// CollectResponse holds the result of a job and is only valid if Ok is set.
+// Error is set instead of Payload if the worker failed.
+type CollectResponse struct {
+	Ok      bool
+	Payload []byte
+	Error   string
+}
+
+// PullRequest waits up to Wait for a job to appear on Topic.
+type PullRequest struct {
+	Topic string
+	Wait  time.Duration
+}
+
+// PullResponse is only valid if Ok is set. The worker must Complete the job before
+// Lease elapses, otherwise the broker hands it to another worker.
+type PullResponse struct {
+	Ok    bool
+	Job   Job
+	Lease time.Duration
+}
+
+type CompleteRequest struct {
+	ID      uint64
+	Payload []byte
+	Error   string
+}
+
+type CompleteResponse struct{}
+
+// Encode gob encodes v into a job payload.
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// Decode gob decodes a job payload into v.
+func Decode(payload []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(v)
+}
@@ -3,6 +3,10 @@ package stubs
 var ReverseHandler = "SecretStringOperations.Reverse"
 var PremiumReverseHandler = "SecretStringOperations.FastReverse"
 
+// Handlers exposed by the broker to the servers sitting behind it.
+var RegisterHandler = "Broker.Register"
+var DeregisterHandler = "Broker.Deregister"
+var StatsHandler = "Broker.Stats"
 
 type Response struct {
 	Message string
@@ -12,4 +16,66 @@ type Request struct {
 	Message string
 }
 
+// WorkerRequest is sent by a server when it joins or leaves the broker.
+// Premium servers only receive PremiumReverseHandler traffic.
+type WorkerRequest struct {
+	Address string
+	Premium bool
+}
+
+type WorkerResponse struct {
+	Workers int
+}
+
+type StatsRequest struct{}
+
+// WorkerStats is a snapshot of a single server as seen by the broker.
+type WorkerStats struct {
+	Address     string
+	Premium     bool
+	Outstanding int
+	Served      int
+	Failed      int
+}
+
+type StatsResponse struct {
+	Workers []WorkerStats
+	Served  int
+	Uptime  float64
+}
+
+// Batch and streaming handlers reverse many messages in a single call.
+// Streamed results are sent back one at a time by calling ResultHandler on the Callback address.
+var ReverseBatchHandler = "SecretStringOperations.ReverseBatch"
+var ReverseStreamHandler = "SecretStringOperations.ReverseStream"
+var ResultHandler = "Results.Deliver"
+
+type BatchRequest struct {
+	Messages []string
+	Premium  bool
+}
+
+// BatchResponse has one entry in Messages and Errors for every request message.
+type BatchResponse struct {
+	Messages []string
+	Errors   []string
+}
+
+type StreamRequest struct {
+	Messages []string
+	Premium  bool
+	Callback string
+}
+
+type StreamResponse struct {
+	Delivered int
+}
+
+// StreamResult is the result for the message at Index in the StreamRequest.
+type StreamResult struct {
+	Index   int
+	Message string
+	Error   string
+}
 
+type StreamAck struct{}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/perf/benchfmt"
+	"golang.org/x/perf/benchmath"
+	"golang.org/x/perf/benchproc"
+)
+
+// The bench command runs BenchmarkGol several times over a sweep of thread counts,
+// sizes and modes, summarises the repeated runs as benchstat does and writes a CSV and
+// an SVG chart of the speed-up over the smallest thread count. It can be run from
+// anywhere inside this module, e.g.
+//
+//	go run ./bench -threads 1-16 -modes channels,shared,stealing -csv results.csv -svg speedup.svg
+//	go run ./bench -in old.txt -svg speedup.svg
+func main() {
+	threads := flag.String("threads", "1-16", "Thread counts to run, as a list such as 1,2,4 and ranges such as 1-16.")
+	sizes := flag.String("sizes", "512x512", "Image sizes to run, e.g. 64x64,512x512.")
+	turns := flag.Int("turns", 1000, "Turns in each run.")
+	modes := flag.String("modes", "channels,shared,stealing", "Modes to run, e.g. channels,shared.")
+	count := flag.Int("count", 10, "Number of times to run each benchmark. At least 6 are needed for a 95% confidence range.")
+	in := flag.String("in", "", "Summarise this file of benchmark output instead of running the benchmarks.")
+	raw := flag.String("raw", "", "Also save the raw benchmark output to this file, for benchstat or -in.")
+	csvPath := flag.String("csv", "results.csv", "Write the summary to this CSV file.")
+	svgPath := flag.String("svg", "speedup.svg", "Write the speed-up chart to this SVG file.")
+	confidence := flag.Float64("confidence", 0.95, "Confidence level for the ranges.")
+	flag.Parse()
+
+	var output []byte
+	var err error
+	if *in != "" {
+		output, err = os.ReadFile(*in)
+	} else {
+		output, err = run(*threads, *sizes, *modes, *turns, *count)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *raw != "" {
+		if err := os.WriteFile(*raw, output, 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	rows, err := summarise(bytes.NewReader(output), *confidence)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(rows) == 0 {
+		fmt.Println("No BenchmarkGol results found")
+		os.Exit(1)
+	}
+	for _, r := range rows {
+		fmt.Printf("%-52v %10.4fs ±%-5v x%.2f\n", r.name, r.seconds, r.rangePct, r.speedup)
+	}
+	if err := writeFile(*csvPath, func(w io.Writer) error { return writeCSV(w, rows) }); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := writeFile(*svgPath, func(w io.Writer) error { return writeSVG(w, rows) }); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Wrote", *csvPath, "and", *svgPath)
+}
+
+// run runs the benchmarks in the current directory, showing their progress on stderr.
+func run(threads, sizes, modes string, turns, count int) ([]byte, error) {
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", "^BenchmarkGol$",
+		"-benchtime", "1x", "-count", strconv.Itoa(count), "uk.ac.bris.cs/gameoflife",
+		"-gol.threads", threads, "-gol.sizes", sizes, "-gol.modes", modes, "-gol.turns", strconv.Itoa(turns))
+	var output bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&output, os.Stderr)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running benchmarks: %v", err)
+	}
+	return output.Bytes(), nil
+}
+
+// row is the summary of one benchmark configuration.
+type row struct {
+	name     string
+	series   string // the configuration apart from the thread count
+	threads  int
+	runs     int
+	seconds  float64
+	lo, hi   float64
+	rangePct string
+	speedup  float64
+}
+
+// summarise groups the sec/op of each configuration and summarises them by their median.
+func summarise(r io.Reader, confidence float64) ([]row, error) {
+	var parser benchproc.ProjectionParser
+	seriesProjection, err := parser.Parse("/mode,/size,/turns", nil)
+	if err != nil {
+		return nil, err
+	}
+	threadsProjection, err := parser.Parse("/threads", nil)
+	if err != nil {
+		return nil, err
+	}
+	threadsField := threadsProjection.Fields()[0]
+
+	type group struct {
+		series  string
+		threads int
+		values  []float64
+	}
+	groups := map[string]*group{}
+	var order []string
+	seriesOrder := map[string]int{}
+
+	reader := benchfmt.NewReader(r, "benchmarks")
+	for reader.Scan() {
+		result, ok := reader.Result().(*benchfmt.Result)
+		if !ok || string(result.Name.Base()) != "Gol" {
+			continue
+		}
+		seconds, ok := result.Value("sec/op")
+		if !ok {
+			continue
+		}
+		series := seriesName(seriesProjection.Project(result))
+		if _, ok := seriesOrder[series]; !ok {
+			seriesOrder[series] = len(seriesOrder)
+		}
+		threads, err := strconv.Atoi(threadsProjection.Project(result).Get(threadsField))
+		if err != nil {
+			return nil, fmt.Errorf("benchmark %s has no thread count", result.Name.Full())
+		}
+		name := "Gol/" + series + "/threads=" + strconv.Itoa(threads)
+		g, ok := groups[name]
+		if !ok {
+			g = &group{series: series, threads: threads}
+			groups[name] = g
+			order = append(order, name)
+		}
+		g.values = append(g.values, seconds)
+	}
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]row, 0, len(order))
+	warned := map[string]bool{}
+	for _, name := range order {
+		g := groups[name]
+		sample := benchmath.NewSample(g.values, &benchmath.DefaultThresholds)
+		summary := benchmath.AssumeNothing.Summary(sample, confidence)
+		for _, warning := range summary.Warnings {
+			if !warned[warning.Error()] {
+				fmt.Println("Warning:", warning)
+				warned[warning.Error()] = true
+			}
+		}
+		rows = append(rows, row{
+			name:     name,
+			series:   g.series,
+			threads:  g.threads,
+			runs:     len(g.values),
+			seconds:  summary.Center,
+			lo:       summary.Lo,
+			hi:       summary.Hi,
+			rangePct: summary.PctRangeString(),
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].series != rows[j].series {
+			return seriesOrder[rows[i].series] < seriesOrder[rows[j].series]
+		}
+		return rows[i].threads < rows[j].threads
+	})
+
+	// Speed-up is relative to the fewest threads each series was run with.
+	for i := range rows {
+		if i == 0 || rows[i].series != rows[i-1].series {
+			base := rows[i].seconds
+			for j := i; j < len(rows) && rows[j].series == rows[i].series; j++ {
+				rows[j].speedup = base / rows[j].seconds
+			}
+		}
+	}
+	return rows, nil
+}
+
+// seriesName formats the configuration in sub-benchmark form, e.g. size=512x512/turns=1000.
+func seriesName(key benchproc.Key) string {
+	var parts []string
+	for _, field := range key.Projection().Fields() {
+		if value := key.Get(field); value != "" {
+			parts = append(parts, strings.TrimPrefix(field.Name, "/")+"="+value)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeCSV(w io.Writer, rows []row) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"name", "series", "threads", "runs", "time", "low", "high", "range", "speedup"})
+	for _, r := range rows {
+		out.Write([]string{
+			r.name,
+			r.series,
+			strconv.Itoa(r.threads),
+			strconv.Itoa(r.runs),
+			strconv.FormatFloat(r.seconds, 'g', -1, 64),
+			strconv.FormatFloat(r.lo, 'g', -1, 64),
+			strconv.FormatFloat(r.hi, 'g', -1, 64),
+			r.rangePct,
+			strconv.FormatFloat(r.speedup, 'f', 3, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+var colours = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// writeSVG draws the speed-up of each series against its thread count, with the ideal linear speed-up dashed.
+func writeSVG(w io.Writer, rows []row) error {
+	const (
+		width, height = 720, 480
+		left, right   = 70, 200
+		top, bottom   = 40, 60
+		plotW         = width - left - right
+		plotH         = height - top - bottom
+	)
+	maxThreads, maxSpeedup := 1, 1.0
+	var series []string
+	for i, r := range rows {
+		if r.threads > maxThreads {
+			maxThreads = r.threads
+		}
+		maxSpeedup = math.Max(maxSpeedup, r.speedup)
+		if i == 0 || r.series != rows[i-1].series {
+			series = append(series, r.series)
+		}
+	}
+	maxSpeedup = math.Ceil(maxSpeedup)
+	x := func(threads int) float64 {
+		return left + float64(threads-1)/math.Max(float64(maxThreads-1), 1)*plotW
+	}
+	y := func(speedup float64) float64 {
+		return top + plotH - speedup/maxSpeedup*plotH
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%v" height="%v" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%v" y="24" font-size="16" text-anchor="middle">Game of Life speed-up against threads</text>`+"\n", left+plotW/2)
+
+	// Grid and axis labels.
+	for t := 1; t <= maxThreads; t++ {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#eee"/>`+"\n", x(t), top, x(t), top+plotH)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%v" text-anchor="middle">%v</text>`+"\n", x(t), top+plotH+18, t)
+	}
+	for s := 0.0; s <= maxSpeedup; s++ {
+		fmt.Fprintf(&b, `<line x1="%v" y1="%.1f" x2="%v" y2="%.1f" stroke="#eee"/>`+"\n", left, y(s), left+plotW, y(s))
+		fmt.Fprintf(&b, `<text x="%v" y="%.1f" text-anchor="end">%v</text>`+"\n", left-8, y(s)+4, s)
+	}
+	fmt.Fprintf(&b, `<rect x="%v" y="%v" width="%v" height="%v" fill="none" stroke="black"/>`+"\n", left, top, plotW, plotH)
+	fmt.Fprintf(&b, `<text x="%v" y="%v" text-anchor="middle">Threads</text>`+"\n", left+plotW/2, height-16)
+	fmt.Fprintf(&b, `<text x="18" y="%v" text-anchor="middle" transform="rotate(-90 18 %v)">Speed-up</text>`+"\n", top+plotH/2, top+plotH/2)
+
+	// Ideal linear speed-up, clipped to the plot.
+	ideal := math.Min(float64(maxThreads), maxSpeedup)
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999" stroke-dasharray="4 4"/>`+"\n",
+		x(1), y(1), x(int(ideal)), y(ideal))
+
+	for i, s := range series {
+		colour := colours[i%len(colours)]
+		var points []string
+		for _, r := range rows {
+			if r.series != s {
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(r.threads), y(r.speedup)))
+			// Error bars from the confidence interval of the time.
+			if r.lo > 0 && !math.IsInf(r.hi, 0) {
+				base := r.speedup * r.seconds
+				fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%v"/>`+"\n",
+					x(r.threads), y(base/r.lo), x(r.threads), y(base/r.hi), colour)
+			}
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%v"><title>%v: %.4fs x%.2f</title></circle>`+"\n",
+				x(r.threads), y(r.speedup), colour, r.name, r.seconds, r.speedup)
+		}
+		fmt.Fprintf(&b, `<polyline points="%v" fill="none" stroke="%v" stroke-width="2"/>`+"\n", strings.Join(points, " "), colour)
+
+		ly := top + 10 + i*20
+		fmt.Fprintf(&b, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="%v" stroke-width="2"/>`+"\n", left+plotW+15, ly, left+plotW+35, ly, colour)
+		fmt.Fprintf(&b, `<text x="%v" y="%v">%v</text>`+"\n", left+plotW+40, ly+4, s)
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
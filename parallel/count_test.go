@@ -20,6 +20,7 @@ func TestAlive(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
 	events := make(chan gol.Event)
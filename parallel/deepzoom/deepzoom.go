@@ -0,0 +1,234 @@
+// Package deepzoom writes worlds as Deep Zoom tile pyramids, which tiled image viewers such
+// as OpenSeadragon can browse however big the world is.
+//
+// The pyramid for a world called name is a name.dzi descriptor and a name_files directory
+// with a directory of PNG tiles for each level. The last level is the world at full size,
+// and each level before it is half the size of the next, down to a single pixel. Each pixel
+// is shaded by the fraction of the cells it covers that are alive, so that the patterns of
+// activity in a world stay visible when it is zoomed out.
+package deepzoom
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// DefaultTileSize is the width and height of tiles, as most Deep Zoom viewers expect.
+const DefaultTileSize = 256
+
+// Writer writes a pyramid a row of the world at a time. It keeps a row of tiles for each
+// level in memory, so it needs about twice as many bytes as TileSize rows of the world
+// have cells, however tall the world is.
+type Writer struct {
+	dir, name     string
+	tileSize      int
+	width, height int
+	levels        []*level
+	closed        bool
+}
+
+// level is one level of the pyramid, built up a row of tiles at a time.
+type level struct {
+	index         int
+	width, height int
+	y             int // rows written so far
+	band          [][]byte
+	bandRows      int
+	// A row waiting to be paired with the next one to make a row of the level below.
+	pendingAlive []uint32
+	pendingArea  []uint32
+	pending      bool
+	scratchAlive []uint32
+	scratchArea  []uint32
+}
+
+// NewWriter starts a pyramid of a world of the given size in dir, creating dir if needed.
+func NewWriter(dir, name string, width, height, tileSize int) (*Writer, error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("cannot write a pyramid of a %vx%v world", width, height)
+	}
+	if tileSize < 1 {
+		return nil, fmt.Errorf("invalid tile size %v", tileSize)
+	}
+	w := &Writer{dir: dir, name: name, tileSize: tileSize, width: width, height: height}
+	// Level n is the world at full size, where 2^(n-1) < max(width, height) <= 2^n.
+	n := 0
+	for 1<<n < width || 1<<n < height {
+		n++
+	}
+	for i := 0; i <= n; i++ {
+		shift := n - i
+		l := &level{
+			index:  i,
+			width:  (width + 1<<shift - 1) >> shift,
+			height: (height + 1<<shift - 1) >> shift,
+		}
+		l.band = make([][]byte, tileSize)
+		for y := range l.band {
+			l.band[y] = make([]byte, l.width)
+		}
+		l.pendingAlive, l.pendingArea = make([]uint32, l.width), make([]uint32, l.width)
+		l.scratchAlive, l.scratchArea = make([]uint32, l.width), make([]uint32, l.width)
+		w.levels = append(w.levels, l)
+	}
+	for _, l := range w.levels {
+		if err := os.MkdirAll(w.levelDir(l), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Levels returns how many levels the pyramid has.
+func (w *Writer) Levels() int {
+	return len(w.levels)
+}
+
+func (w *Writer) levelDir(l *level) string {
+	return filepath.Join(w.dir, w.name+"_files", fmt.Sprint(l.index))
+}
+
+// WriteRow adds the next row of the world, with true for alive cells.
+func (w *Writer) WriteRow(cells []bool) error {
+	top := w.levels[len(w.levels)-1]
+	if len(cells) != w.width {
+		return fmt.Errorf("row has %v cells, expected %v", len(cells), w.width)
+	}
+	if top.y == w.height {
+		return errors.New("world has more rows than its height")
+	}
+	alive, area := top.scratchAlive, top.scratchArea
+	for x, cell := range cells {
+		alive[x], area[x] = 0, 1
+		if cell {
+			alive[x] = 1
+		}
+	}
+	return w.push(top, alive, area)
+}
+
+// push adds a row to level l, where each pixel covers area cells of which alive are alive.
+func (w *Writer) push(l *level, alive, area []uint32) error {
+	row := l.band[l.bandRows]
+	for x := range row {
+		row[x] = shade(alive[x], area[x])
+	}
+	l.bandRows++
+	l.y++
+	if l.bandRows == w.tileSize || l.y == l.height {
+		if err := w.writeBand(l); err != nil {
+			return err
+		}
+	}
+
+	if l.index == 0 {
+		return nil
+	}
+	if !l.pending {
+		copy(l.pendingAlive, alive)
+		copy(l.pendingArea, area)
+		l.pending = true
+		return nil
+	}
+	l.pending = false
+	for x := range alive {
+		l.pendingAlive[x] += alive[x]
+		l.pendingArea[x] += area[x]
+	}
+	return w.pushHalved(l)
+}
+
+// pushHalved adds l's pending row to the level below, pairing up its pixels.
+func (w *Writer) pushHalved(l *level) error {
+	below := w.levels[l.index-1]
+	alive, area := below.scratchAlive, below.scratchArea
+	for x := range alive {
+		alive[x], area[x] = l.pendingAlive[2*x], l.pendingArea[2*x]
+		if 2*x+1 < l.width {
+			alive[x] += l.pendingAlive[2*x+1]
+			area[x] += l.pendingArea[2*x+1]
+		}
+	}
+	return w.push(below, alive, area)
+}
+
+// shade maps the fraction of alive cells to a grey level. The square root brightens
+// sparse regions, which would otherwise be almost black when zoomed out.
+func shade(alive, area uint32) byte {
+	return byte(math.Round(255 * math.Sqrt(float64(alive)/float64(area))))
+}
+
+// writeBand writes the band of rows that l has collected as a row of tiles.
+func (w *Writer) writeBand(l *level) error {
+	row := (l.y - 1) / w.tileSize
+	for column := 0; column*w.tileSize < l.width; column++ {
+		startX := column * w.tileSize
+		endX := startX + w.tileSize
+		if endX > l.width {
+			endX = l.width
+		}
+		tile := image.NewGray(image.Rect(0, 0, endX-startX, l.bandRows))
+		for y := 0; y < l.bandRows; y++ {
+			copy(tile.Pix[y*tile.Stride:], l.band[y][startX:endX])
+		}
+		if err := writePng(filepath.Join(w.levelDir(l), fmt.Sprintf("%v_%v.png", column, row)), tile); err != nil {
+			return err
+		}
+	}
+	l.bandRows = 0
+	return nil
+}
+
+func writePng(path string, tile image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, tile); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close finishes the levels below the world's last row and writes the descriptor. It fails
+// if fewer rows were written than the world's height.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errors.New("pyramid is already closed")
+	}
+	w.closed = true
+	top := w.levels[len(w.levels)-1]
+	if top.y != w.height {
+		return fmt.Errorf("world has %v rows, expected %v", top.y, w.height)
+	}
+	// A level with an odd number of rows has its last row left unpaired.
+	for i := len(w.levels) - 1; i > 0; i-- {
+		if l := w.levels[i]; l.pending {
+			l.pending = false
+			if err := w.pushHalved(l); err != nil {
+				return err
+			}
+		}
+	}
+
+	file, err := os.Create(filepath.Join(w.dir, w.name+".dzi"))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, `<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="png" Overlap="0" TileSize="%v">
+  <Size Width="%v" Height="%v"/>
+</Image>
+`, w.tileSize, w.width, w.height)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
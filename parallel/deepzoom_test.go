@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/deepzoom"
+)
+
+// readPyramidLevel reads a level of a pyramid back from its tiles.
+func readPyramidLevel(t *testing.T, dir, name string, level, width, height, tileSize int) [][]byte {
+	pixels := make([][]byte, height)
+	for y := range pixels {
+		pixels[y] = make([]byte, width)
+	}
+	for row := 0; row*tileSize < height; row++ {
+		for column := 0; column*tileSize < width; column++ {
+			path := filepath.Join(dir, name+"_files", fmt.Sprint(level), fmt.Sprintf("%v_%v.png", column, row))
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tile, err := png.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gray := tile.(*image.Gray)
+			tileWidth, tileHeight := min(tileSize, width-column*tileSize), min(tileSize, height-row*tileSize)
+			if size := gray.Bounds().Size(); size != image.Pt(tileWidth, tileHeight) {
+				t.Fatalf("ERROR: Tile %v is %v, expected %vx%v", path, size, tileWidth, tileHeight)
+			}
+			for y := 0; y < tileHeight; y++ {
+				for x := 0; x < tileWidth; x++ {
+					pixels[row*tileSize+y][column*tileSize+x] = gray.GrayAt(x, y).Y
+				}
+			}
+		}
+	}
+	return pixels
+}
+
+// TestDeepZoom writes pyramids of random worlds of awkward sizes and checks every pixel of
+// every level against the density of the cells it covers.
+func TestDeepZoom(t *testing.T) {
+	sizes := [][3]int{{1, 1, 256}, {5, 3, 2}, {16, 16, 4}, {37, 91, 16}, {100, 7, 32}, {64, 64, 256}}
+	for _, size := range sizes {
+		width, height, tileSize := size[0], size[1], size[2]
+		t.Run(fmt.Sprintf("%dx%d-%d", width, height, tileSize), func(t *testing.T) {
+			random := rand.New(rand.NewSource(int64(width * height)))
+			world := make([][]bool, height)
+			for y := range world {
+				world[y] = make([]bool, width)
+				for x := range world[y] {
+					world[y][x] = random.Intn(3) == 0
+				}
+			}
+			dir := t.TempDir()
+			w, err := deepzoom.NewWriter(dir, "world", width, height, tileSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range world {
+				if err := w.WriteRow(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			levels := w.Levels()
+			assert(t, 1<<(levels-1) >= max(width, height) && 1<<(levels-1) < 2*max(width, height) || levels == 1,
+				"%v levels for a %vx%v world", levels, width, height)
+			for level := 0; level < levels; level++ {
+				scale := 1 << (levels - 1 - level)
+				levelWidth, levelHeight := (width+scale-1)/scale, (height+scale-1)/scale
+				pixels := readPyramidLevel(t, dir, "world", level, levelWidth, levelHeight, tileSize)
+				for y := range pixels {
+					for x := range pixels[y] {
+						alive, area := 0, 0
+						for cellY := y * scale; cellY < min((y+1)*scale, height); cellY++ {
+							for cellX := x * scale; cellX < min((x+1)*scale, width); cellX++ {
+								area++
+								if world[cellY][cellX] {
+									alive++
+								}
+							}
+						}
+						expected := byte(math.Round(255 * math.Sqrt(float64(alive)/float64(area))))
+						if pixels[y][x] != expected {
+							t.Fatalf("ERROR: Level %v pixel (%v, %v) is %v, expected %v", level, x, y, pixels[y][x], expected)
+						}
+					}
+				}
+			}
+
+			dzi, err := os.ReadFile(filepath.Join(dir, "world.dzi"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := fmt.Sprintf(`TileSize="%v">
+  <Size Width="%v" Height="%v"/>`, tileSize, width, height)
+			assert(t, strings.Contains(string(dzi), expected), "Descriptor %q does not contain %q", dzi, expected)
+		})
+	}
+}
+
+// TestDeepZoomImage writes a pyramid of a check image, whose full size level must be the image.
+func TestDeepZoomImage(t *testing.T) {
+	expectedAlive := readAliveCells("check/images/512x512x100.pgm", 512, 512)
+	world := make([][]bool, 512)
+	for y := range world {
+		world[y] = make([]bool, 512)
+	}
+	for _, cell := range expectedAlive {
+		world[cell.Y][cell.X] = true
+	}
+	dir := t.TempDir()
+	w, err := deepzoom.NewWriter(dir, "512x512x100", 512, 512, deepzoom.DefaultTileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range world {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert(t, w.Levels() == 10, "%v levels for a 512x512 world, expected 10", w.Levels())
+	pixels := readPyramidLevel(t, dir, "512x512x100", 9, 512, 512, deepzoom.DefaultTileSize)
+	for y := range pixels {
+		for x := range pixels[y] {
+			if (pixels[y][x] == 255) != world[y][x] || pixels[y][x] != 0 && pixels[y][x] != 255 {
+				t.Fatalf("ERROR: Pixel (%v, %v) is %v, expected the cell's colour", x, y, pixels[y][x])
+			}
+		}
+	}
+	pixels = readPyramidLevel(t, dir, "512x512x100", 0, 1, 1, deepzoom.DefaultTileSize)
+	expected := byte(math.Round(255 * math.Sqrt(float64(len(expectedAlive))/(512*512))))
+	assert(t, pixels[0][0] == expected, "Level 0 is %v, expected %v", pixels[0][0], expected)
+}
+
+// TestDeepZoomErrors checks rows of the wrong width or number are refused.
+func TestDeepZoomErrors(t *testing.T) {
+	_, err := deepzoom.NewWriter(t.TempDir(), "world", 0, 4, 256)
+	assert(t, err != nil, "Pyramid of an empty world was started")
+
+	w, err := deepzoom.NewWriter(t.TempDir(), "world", 4, 2, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert(t, w.WriteRow(make([]bool, 3)) != nil, "Row of 3 cells was written to a world 4 wide")
+	assert(t, w.WriteRow(make([]bool, 4)) == nil, "Row of 4 cells was not written")
+	assert(t, w.Close() != nil, "Pyramid was closed with a row missing")
+}
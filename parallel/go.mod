@@ -2,9 +2,9 @@ module uk.ac.bris.cs/gameoflife
 
 go 1.17
 
-require github.com/veandco/go-sdl2 v0.4.38
-
 require (
-	github.com/aclements/go-moremath v0.0.0-20210112150236-f10218a38794 // indirect
-	golang.org/x/perf v0.0.0-20241004173025-94b0db8a2472 // indirect
+	github.com/veandco/go-sdl2 v0.4.38
+	golang.org/x/perf v0.0.0-20241004173025-94b0db8a2472
 )
+
+require github.com/aclements/go-moremath v0.0.0-20210112150236-f10218a38794 // indirect
@@ -0,0 +1,37 @@
+package gol
+
+import "sync"
+
+// barrier blocks each caller of wait until n callers are waiting, then releases them all.
+// It can be reused for the next round straight away.
+type barrier struct {
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	n          int
+	waiting    int
+	generation int
+}
+
+func newBarrier(n int) *barrier {
+	b := &barrier{n: n}
+	b.cond = sync.NewCond(&b.mutex)
+	return b
+}
+
+func (b *barrier) wait() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	generation := b.generation
+	b.waiting++
+	if b.waiting == b.n {
+		b.waiting = 0
+		b.generation++
+		b.cond.Broadcast()
+		return
+	}
+	// The generation changes when the last caller arrives, which also stops spurious wake ups
+	// and callers of the next round from being let through early.
+	for generation == b.generation {
+		b.cond.Wait()
+	}
+}
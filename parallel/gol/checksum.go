@@ -0,0 +1,86 @@
+package gol
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// strip is a worker's result: the next state of its tile, its checksum,
+// how many of its cells are alive, which worker computed it and how long that took.
+type strip struct {
+	tile
+	worker   int
+	cells    [][]byte
+	checksum uint64
+	alive    int
+	duration time.Duration
+}
+
+// faultInjector, if set, is called by every worker on the strip it has just computed.
+// It lets tests damage results to check that verification catches them.
+var faultInjector func(t tile, cells [][]byte)
+
+// stripChecksum hashes the cells a strip was computed from, including the halo
+// of cells around its tile, followed by the strip's computed rows.
+func stripChecksum(world [][]byte, t tile, cells [][]byte) uint64 {
+	h := fnv.New64a()
+	height := len(world)
+	width := len(world[0])
+	for y := t.startY - 1; y <= t.endY; y++ {
+		row := world[(y+height)%height]
+		if t.width() == width {
+			h.Write(row)
+			continue
+		}
+		h.Write([]byte{row[(t.startX-1+width)%width]})
+		h.Write(row[t.startX:t.endX])
+		h.Write([]byte{row[t.endX%width]})
+	}
+	for _, row := range cells {
+		h.Write(row)
+	}
+	return h.Sum64()
+}
+
+// verifyStrips recomputes a random sample of the strips on separate workers.
+// A strip whose checksum does not match its recomputation is replaced by the
+// recomputed one and reported with a StripMismatch event.
+func verifyStrips(strips []strip, p Params, world [][]byte, c distributorChannels) {
+	// Recomputing must not send CellFlipped events a second time.
+	silent := c
+	silent.events = nil
+
+	var wg sync.WaitGroup
+	mismatched := make([]bool, len(strips))
+	for i := range strips {
+		if rand.Float64() >= p.VerifyRate {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := strips[i]
+			region := trace.StartRegion(c.ctx, verifyRegion)
+			cells := calculateNextState(s.startY, s.endY, s.startX, s.endX, p, world, silent)
+			region.End()
+			if stripChecksum(world, s.tile, cells) != s.checksum {
+				// Copied in place, as in the Shared and Stealing modes the strip's cells belong to the next world.
+				for y, row := range cells {
+					copy(strips[i].cells[y], row)
+				}
+				strips[i].alive = countAlive(cells)
+				mismatched[i] = true
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, s := range strips {
+		if mismatched[i] {
+			c.events <- StripMismatch{CompletedTurns: c.completedTurns, StartX: s.startX, EndX: s.endX, StartY: s.startY, EndY: s.endY}
+		}
+	}
+}
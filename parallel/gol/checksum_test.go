@@ -0,0 +1,52 @@
+package gol
+
+import (
+	"context"
+	"testing"
+)
+
+// TestVerifyStripsCatchesFaults damages one worker's strip and checks that
+// verification replaces it with the correct rows and raises StripMismatch.
+func TestVerifyStripsCatchesFaults(t *testing.T) {
+	p := Params{Threads: 4, ImageWidth: 16, ImageHeight: 16, VerifyRate: 1}
+	world := initWorld(p.ImageHeight, p.ImageWidth)
+	// A blinker crossing the boundary between the first two strips.
+	world[3][5], world[4][5], world[5][5] = 255, 255, 255
+
+	faultInjector = func(t tile, cells [][]byte) {
+		if t.startY == 4 {
+			cells[0][0] = 255
+		}
+	}
+	defer func() { faultInjector = nil }()
+
+	events := make(chan Event, 1000)
+	c := distributorChannels{events: events, completedTurns: 1, ctx: context.Background()}
+	strips := make([]strip, p.Threads)
+	for i := range strips {
+		results := make(chan strip, 1)
+		worker(tile{startX: 0, endX: p.ImageWidth, startY: i * 4, endY: (i + 1) * 4}, initWorld(4, p.ImageWidth), p, world, c, results)
+		strips[i] = <-results
+	}
+	verifyStrips(strips, p, world, c)
+	close(events)
+
+	mismatches := 0
+	for event := range events {
+		if e, ok := event.(StripMismatch); ok {
+			mismatches++
+			if e.StartY != 4 || e.EndY != 8 {
+				t.Errorf("ERROR: Expected mismatch for rows 4-8, got %v-%v", e.StartY, e.EndY)
+			}
+		}
+	}
+	if mismatches != 1 {
+		t.Errorf("ERROR: Expected 1 StripMismatch event, got %v", mismatches)
+	}
+	if strips[1].cells[0][0] != 0 {
+		t.Error("ERROR: Damaged cell was not recomputed")
+	}
+	if strips[0].cells[3][5] != 0 || strips[1].cells[0][4] != 255 || strips[1].cells[0][5] != 255 || strips[1].cells[0][6] != 255 {
+		t.Error("ERROR: Blinker was not rotated correctly")
+	}
+}
@@ -0,0 +1,357 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"runtime/trace"
+	"strconv"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// diskReportLimit is the most alive cells an out-of-core game reports in FinalTurnComplete.
+// Past it the list would not fit in memory either, and the final image holds the world.
+const diskReportLimit = 1 << 24
+
+// diskWorld is the world of an out-of-core game, used when Params.OutOfCore is set. It is
+// stored in a file of full-width rows with eight cells to a byte, the least significant bit
+// first, and each row padded to a whole byte. Turns are written into a second file and the
+// two swap. Each worker steps a band of whole rows, and only three rows per worker are ever
+// in memory, so worlds can be far larger than RAM.
+type diskWorld struct {
+	width, height int
+	rowBytes      int
+	current, next *os.File
+	alive         int
+}
+
+// newDiskWorld creates the two files of an empty world in dir.
+func newDiskWorld(dir string, width, height int) (*diskWorld, error) {
+	d := &diskWorld{width: width, height: height, rowBytes: (width + 7) / 8}
+	var err error
+	if d.current, err = os.CreateTemp(dir, "gol-*.bits"); err != nil {
+		return nil, err
+	}
+	if d.next, err = os.CreateTemp(dir, "gol-*.bits"); err != nil {
+		d.close()
+		return nil, err
+	}
+	// Truncating fills the files with dead cells, without writing them on most filesystems.
+	size := int64(height) * int64(d.rowBytes)
+	for _, file := range []*os.File{d.current, d.next} {
+		if err = file.Truncate(size); err != nil {
+			d.close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// close removes the world's files.
+func (d *diskWorld) close() {
+	for _, file := range []*os.File{d.current, d.next} {
+		if file != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}
+}
+
+func (d *diskWorld) readRow(y int, packed []byte) {
+	_, err := d.current.ReadAt(packed, int64(y)*int64(d.rowBytes))
+	util.Check(err)
+}
+
+// writeRow writes a row of the current turn, while the world is being loaded.
+func (d *diskWorld) writeRow(y int, packed []byte) {
+	_, err := d.current.WriteAt(packed, int64(y)*int64(d.rowBytes))
+	util.Check(err)
+	for _, b := range packed {
+		d.alive += bits.OnesCount8(b)
+	}
+}
+
+// unpack expands a packed row into one byte per cell, 1 for alive and 0 for dead.
+func unpack(packed, cells []byte) {
+	for x := range cells {
+		cells[x] = packed[x>>3] >> (x & 7) & 1
+	}
+}
+
+// loadPgm reads the world from a PGM image a row at a time.
+func (d *diskWorld) loadPgm(r *bufio.Reader) error {
+	var magic string
+	var width, height, maxval int
+	if _, err := fmt.Fscan(r, &magic, &width, &height, &maxval); err != nil {
+		return err
+	}
+	if magic != "P5" || width != d.width || height != d.height || maxval != 255 {
+		return fmt.Errorf("expected a %vx%v P5 image with maxval 255, got %v %vx%v with maxval %v", d.width, d.height, magic, width, height, maxval)
+	}
+	// A single whitespace character separates the header from the cells.
+	if _, err := r.ReadByte(); err != nil {
+		return err
+	}
+	row := make([]byte, d.width)
+	packed := make([]byte, d.rowBytes)
+	for y := 0; y < d.height; y++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return err
+		}
+		for i := range packed {
+			packed[i] = 0
+		}
+		for x, cell := range row {
+			if cell == 255 {
+				packed[x>>3] |= 1 << (x & 7)
+			}
+		}
+		d.writeRow(y, packed)
+	}
+	return nil
+}
+
+// step computes the turn after the current one into the next file and swaps the files.
+// The rows are split into a band for each of p.Threads workers, and each worker slides a
+// window of three rows down its band. It returns how long each worker spent and how many
+// cells are alive. No CellFlipped events are sent, as no window could show such a world.
+func (d *diskWorld) step(p Params, c distributorChannels) ([]time.Duration, int) {
+	threads := p.Threads
+	if threads > d.height {
+		threads = d.height
+	}
+	type result struct {
+		alive    int
+		duration time.Duration
+	}
+	results := make(chan result, threads)
+	for w := 0; w < threads; w++ {
+		startY, endY := w*d.height/threads, (w+1)*d.height/threads
+		go func() {
+			start := time.Now()
+			region := trace.StartRegion(c.ctx, workerRegion)
+			alive := d.stepRows(startY, endY)
+			region.End()
+			results <- result{alive, time.Since(start)}
+		}()
+	}
+
+	workerTimes := make([]time.Duration, threads)
+	d.alive = 0
+	for w := range workerTimes {
+		r := <-results
+		workerTimes[w] = r.duration
+		d.alive += r.alive
+	}
+	d.current, d.next = d.next, d.current
+	return workerTimes, d.alive
+}
+
+// bandRow is a row being stepped, held as 64 cells to a word, with the bit-sliced sums of
+// every cell's horizontal neighbours. Bit x of sum1 and sum0 is the two bit count of cells
+// x-1, x and x+1 that are alive, and of side1 and side0 the count of just x-1 and x+1.
+type bandRow struct {
+	cells, sum0, sum1, side0, side1 []uint64
+}
+
+func newBandRow(words int) *bandRow {
+	return &bandRow{
+		cells: make([]uint64, words),
+		sum0:  make([]uint64, words),
+		sum1:  make([]uint64, words),
+		side0: make([]uint64, words),
+		side1: make([]uint64, words),
+	}
+}
+
+// load reads row y, wrapping around the top and bottom of the world, using buf, which
+// holds a whole number of words, to convert it. Its padding must be zero.
+func (d *diskWorld) load(y int, r *bandRow, buf []byte) {
+	d.readRow(wrap(y, d.height), buf[:d.rowBytes])
+	for i := range r.cells {
+		r.cells[i] = binary.LittleEndian.Uint64(buf[i*8:])
+	}
+
+	// The neighbours of the cells at either end of the row are found at the other end.
+	last := len(r.cells) - 1
+	lastBit := uint(d.width-1) % 64
+	firstCell, lastCell := r.cells[0]&1, r.cells[last]>>lastBit&1
+	for i, w := range r.cells {
+		west, east := w<<1, w>>1
+		if i > 0 {
+			west |= r.cells[i-1] >> 63
+		} else {
+			west |= lastCell
+		}
+		if i < last {
+			east |= r.cells[i+1] << 63
+		} else {
+			east |= firstCell << lastBit
+		}
+		r.side0[i], r.side1[i] = west^east, west&east
+		r.sum0[i], r.sum1[i] = west^east^w, west&east|w&(west^east)
+	}
+}
+
+// stepRows computes rows startY to endY of the next turn and returns how many cells in them
+// are alive. Neighbours are counted for 64 cells at once with bitwise adders.
+func (d *diskWorld) stepRows(startY, endY int) int {
+	words := (d.width + 63) / 64
+	buf := make([]byte, words*8)
+	above, row, below := newBandRow(words), newBandRow(words), newBandRow(words)
+	out := make([]uint64, words)
+	outBuf := make([]byte, words*8)
+	// Only the bits of the last word that hold cells may be set, so the padding stays dead.
+	lastMask := ^uint64(0) >> (uint(words*64-d.width) % 64)
+	d.load(startY-1, above, buf)
+	d.load(startY, row, buf)
+
+	alive := 0
+	for y := startY; y < endY; y++ {
+		d.load(y+1, below, buf)
+		for i := range out {
+			// Add the counts of the rows above and below, at most 3 each, to that of the
+			// row's own neighbours, at most 2. The neighbours number bit0 plus twice the
+			// number of set bits among above.sum1, below.sum1, row.side1 and carry.
+			bit0 := above.sum0[i] ^ below.sum0[i] ^ row.side0[i]
+			carry := above.sum0[i]&below.sum0[i] | row.side0[i]&(above.sum0[i]^below.sum0[i])
+			pair1, pair2 := above.sum1[i]^below.sum1[i], row.side1[i]^carry
+			both := above.sum1[i]&below.sum1[i] | row.side1[i]&carry
+			// Two or three neighbours is exactly one of the four set, plus bit0.
+			twoOrThree := (pair1 ^ pair2) &^ both
+			out[i] = twoOrThree & (bit0 | row.cells[i])
+		}
+		out[words-1] &= lastMask
+		for i, w := range out {
+			alive += bits.OnesCount64(w)
+			binary.LittleEndian.PutUint64(outBuf[i*8:], w)
+		}
+		_, err := d.next.WriteAt(outBuf[:d.rowBytes], int64(y)*int64(d.rowBytes))
+		util.Check(err)
+		above, row, below = row, below, above
+	}
+	return alive
+}
+
+// aliveCells returns the alive cells, or nil if there are more than diskReportLimit.
+func (d *diskWorld) aliveCells() []util.Cell {
+	if d.alive > diskReportLimit {
+		return nil
+	}
+	var cells []util.Cell
+	packed := make([]byte, d.rowBytes)
+	for y := 0; y < d.height; y++ {
+		d.readRow(y, packed)
+		for i, b := range packed {
+			for ; b != 0; b &= b - 1 {
+				cells = append(cells, util.Cell{X: i*8 + bits.TrailingZeros8(b), Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// writePgm writes the world as a PGM image, a row at a time.
+func (d *diskWorld) writePgm(w *bufio.Writer) error {
+	if _, err := fmt.Fprintf(w, "P5\n%v %v\n255\n", d.width, d.height); err != nil {
+		return err
+	}
+	packed := make([]byte, d.rowBytes)
+	cells := make([]byte, d.width)
+	for y := 0; y < d.height; y++ {
+		d.readRow(y, packed)
+		unpack(packed, cells)
+		for x, cell := range cells {
+			cells[x] = 255 * cell
+		}
+		if _, err := w.Write(cells); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// loadRle reads the world from an RLE pattern, placed at its top left corner.
+func (d *diskWorld) loadRle(r *bufio.Reader) error {
+	pattern, err := NewRleReader(r)
+	if err != nil {
+		return err
+	}
+	if pattern.Width > d.width || pattern.Height > d.height {
+		return fmt.Errorf("RLE pattern is %vx%v, expected at most %vx%v", pattern.Width, pattern.Height, d.width, d.height)
+	}
+	packed := make([]byte, d.rowBytes)
+	for y := 0; y < pattern.Height; y++ {
+		cells, err := pattern.ReadRow()
+		if err != nil {
+			return err
+		}
+		for i := range packed {
+			packed[i] = 0
+		}
+		empty := true
+		for x, alive := range cells {
+			if alive {
+				packed[x>>3] |= 1 << (x & 7)
+				empty = false
+			}
+		}
+		// The files start out dead, so empty rows need not be written.
+		if !empty {
+			d.writeRow(y, packed)
+		}
+	}
+	return nil
+}
+
+// writeRle writes the world as an RLE pattern, a row at a time.
+func (d *diskWorld) writeRle(w *bufio.Writer) error {
+	r := newRleWriter(w, d.width, d.height)
+	packed := make([]byte, d.rowBytes)
+	cells := make([]byte, d.width)
+	for y := 0; y < d.height; y++ {
+		d.readRow(y, packed)
+		unpack(packed, cells)
+		for x := 0; x < d.width; {
+			run := x + 1
+			for run < d.width && cells[run] == cells[x] {
+				run++
+			}
+			r.cells(cells[x] == 1, run-x)
+			x = run
+		}
+		r.endRow()
+	}
+	return r.close()
+}
+
+// loadDiskWorld creates the world of an out-of-core game in p.OutOfCore and reads its
+// image into it. A PGM image is used if there is one, and otherwise an RLE pattern with
+// the same name, which lets huge worlds start without a huge image.
+func loadDiskWorld(p Params) *diskWorld {
+	d, err := newDiskWorld(p.OutOfCore, p.ImageWidth, p.ImageHeight)
+	util.Check(err)
+	filename := strconv.Itoa(p.ImageHeight) + "x" + strconv.Itoa(p.ImageWidth)
+	load := d.loadPgm
+	file, err := os.Open("images/" + filename + ".pgm")
+	if os.IsNotExist(err) {
+		load = d.loadRle
+		file, err = os.Open("images/" + filename + ".rle")
+	}
+	if err != nil {
+		d.close()
+		util.Check(err)
+	}
+	defer file.Close()
+	if err := load(bufio.NewReaderSize(file, 1<<20)); err != nil {
+		d.close()
+		util.Check(err)
+	}
+	fmt.Println("File", filename, "input done!")
+	return d
+}
@@ -0,0 +1,210 @@
+package gol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func randomWorld(width, height int, seed int64) [][]byte {
+	world := initWorld(height, width)
+	random := rand.New(rand.NewSource(seed))
+	for _, row := range world {
+		for x := range row {
+			if random.Intn(3) == 0 {
+				row[x] = 255
+			}
+		}
+	}
+	return world
+}
+
+// newTestDiskWorld stores world in a diskWorld in a temporary directory.
+func newTestDiskWorld(t *testing.T, world [][]byte) *diskWorld {
+	d, err := newDiskWorld(t.TempDir(), len(world[0]), len(world))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := make([]byte, d.rowBytes)
+	for y, row := range world {
+		for i := range packed {
+			packed[i] = 0
+		}
+		for x, cell := range row {
+			if cell == 255 {
+				packed[x>>3] |= 1 << (x & 7)
+			}
+		}
+		d.writeRow(y, packed)
+	}
+	return d
+}
+
+// TestDiskMatchesDense checks the out-of-core world against the dense engine on random
+// worlds, including ones whose rows do not fill a whole byte and with more threads than rows.
+func TestDiskMatchesDense(t *testing.T) {
+	sizes := [][2]int{{1, 1}, {3, 5}, {9, 2}, {16, 16}, {37, 91}, {64, 64}, {65, 3}, {130, 9}, {192, 4}}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		for _, threads := range []int{1, 3, 8} {
+			t.Run(fmt.Sprintf("%dx%d-%d", width, height, threads), func(t *testing.T) {
+				p := Params{ImageWidth: width, ImageHeight: height, Threads: threads}
+				world := randomWorld(width, height, int64(width*height))
+				d := newTestDiskWorld(t, world)
+				defer d.close()
+				for turn := 1; turn <= 10; turn++ {
+					next := initWorld(height, width)
+					calculateNextStateInto(next, 0, height, 0, width, p, world, distributorChannels{})
+					world = next
+					_, alive := d.step(p, distributorChannels{})
+
+					expected := calculateAliveCells(p, world)
+					cells := d.aliveCells()
+					if fmt.Sprint(cells) != fmt.Sprint(expected) {
+						t.Fatalf("ERROR: Turn %v has alive cells %v, expected %v", turn, cells, expected)
+					}
+					if alive != len(expected) {
+						t.Fatalf("ERROR: Turn %v counted %v alive cells, expected %v", turn, alive, len(expected))
+					}
+				}
+
+				var pgm bytes.Buffer
+				if err := d.writePgm(bufio.NewWriter(&pgm)); err != nil {
+					t.Fatal(err)
+				}
+				header := fmt.Sprintf("P5\n%v %v\n255\n", width, height)
+				if expected := header + string(bytes.Join(world, nil)); pgm.String() != expected {
+					t.Errorf("ERROR: PGM image is %q, expected %q", pgm.String(), expected)
+				}
+			})
+		}
+	}
+}
+
+// TestRle writes random worlds as RLE patterns and reads them back, and checks a glider
+// is written the way other Life programs write it.
+func TestRle(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {5, 3}, {16, 16}, {200, 40}} {
+		width, height := size[0], size[1]
+		world := randomWorld(width, height, int64(width+height))
+		// Empty rows in the middle and at the end are written as runs of row ends.
+		for _, y := range []int{height / 2, height - 1} {
+			for x := range world[y] {
+				world[y][x] = 0
+			}
+		}
+		d := newTestDiskWorld(t, world)
+		var rle bytes.Buffer
+		if err := d.writeRle(bufio.NewWriter(&rle)); err != nil {
+			t.Fatal(err)
+		}
+		d.close()
+		for _, line := range strings.Split(rle.String(), "\n") {
+			if len(line) > rleLineLength {
+				t.Errorf("ERROR: RLE line %q is longer than %v", line, rleLineLength)
+			}
+		}
+
+		d = newTestDiskWorld(t, initWorld(height, width))
+		if err := d.loadRle(bufio.NewReader(&rle)); err != nil {
+			t.Fatal(err)
+		}
+		p := Params{ImageWidth: width, ImageHeight: height}
+		if cells, expected := d.aliveCells(), calculateAliveCells(p, world); fmt.Sprint(cells) != fmt.Sprint(expected) {
+			t.Errorf("ERROR: %vx%v pattern read back as %v, expected %v", width, height, cells, expected)
+		}
+		d.close()
+	}
+
+	world := initWorld(8, 8)
+	world[5][4], world[6][5], world[7][3], world[7][4], world[7][5] = 255, 255, 255, 255, 255
+	d := newTestDiskWorld(t, world)
+	defer d.close()
+	var rle bytes.Buffer
+	if err := d.writeRle(bufio.NewWriter(&rle)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "x = 8, y = 8, rule = B3/S23\n5$4bo$5bo$3b3o!\n"; rle.String() != expected {
+		t.Errorf("ERROR: Glider is %q, expected %q", rle.String(), expected)
+	}
+}
+
+// TestRleReader reads patterns with comments, line breaks and bad input.
+func TestRleReader(t *testing.T) {
+	pattern := "#N Glider\n#C A comment\nx = 3, y = 5, rule = b3/s23\nbo$2b\no2$3o\n!"
+	var rows []string
+	r, err := NewRleReader(bufio.NewReader(strings.NewReader(pattern)))
+	for err == nil {
+		var cells []bool
+		if cells, err = r.ReadRow(); err == nil {
+			rows = append(rows, fmt.Sprint(cells))
+		}
+	}
+	expected := []string{"[false true false]", "[false false true]", "[false false false]", "[true true true]", "[false false false]"}
+	if err != io.EOF || fmt.Sprint(rows) != fmt.Sprint(expected) {
+		t.Errorf("ERROR: Read rows %v with error %v, expected %v", rows, err, expected)
+	}
+
+	for _, bad := range []string{
+		"x = 3\n!",
+		"x = 3, y = 3, rule = B36/S23\no!",
+		"x = 3, y = 3\n4o!",
+		"x = 3, y = 3\nobo",
+		"x = 3, y = 3\no?!",
+	} {
+		r, err := NewRleReader(bufio.NewReader(strings.NewReader(bad)))
+		for err == nil {
+			_, err = r.ReadRow()
+		}
+		if err == io.EOF {
+			t.Errorf("ERROR: Read %q without an error", bad)
+		}
+	}
+
+	// Loading into a 3x3 world also rejects a pattern that is too big for it.
+	for _, bad := range []string{
+		"x = 3, y = 3\n4o!",
+		"x = 3, y = 3, rule = B36/S23\no!",
+		"x = 9, y = 3\no!",
+		"x = 3, y = 3\nobo",
+		"x = 3, y = 3\no?!",
+	} {
+		d, err := newDiskWorld(t.TempDir(), 3, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.loadRle(bufio.NewReader(strings.NewReader(bad))) == nil {
+			t.Errorf("ERROR: Loaded %q into a 3x3 world without an error", bad)
+		}
+		d.close()
+	}
+}
+
+// BenchmarkDiskStep measures a turn of a 4096x4096 out-of-core world, in cells per second.
+func BenchmarkDiskStep(b *testing.B) {
+	const size = 4096
+	d, err := newDiskWorld(b.TempDir(), size, size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.close()
+	random := rand.New(rand.NewSource(1))
+	packed := make([]byte, d.rowBytes)
+	for y := 0; y < size; y++ {
+		random.Read(packed)
+		d.writeRow(y, packed)
+	}
+	p := Params{ImageWidth: size, ImageHeight: size, Threads: 1}
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		d.step(p, distributorChannels{ctx: context.Background()})
+	}
+	b.ReportMetric(float64(size*size)*float64(b.N)/time.Since(start).Seconds(), "cells/s")
+}
@@ -1,10 +1,18 @@
 package gol
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/trace"
 	"strconv"
 	"strings"
 	"time"
 
+	"uk.ac.bris.cs/gameoflife/metrics"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -15,17 +23,86 @@ type distributorChannels struct {
 	ioFilename     chan<- string
 	ioOutput       chan<- uint8
 	ioInput        <-chan uint8
+	ioSize         chan<- [2]int
 	completedTurns int
 	keyPresses     <-chan rune
+	ctx            context.Context  // for execution trace regions
+	metrics        *metrics.Metrics // where the game's performance is recorded
+	outDir         string           // where out-of-core images are written
 }
 
-func worker(startY, endY, startX, endX int, p Params, world [][]byte, c distributorChannels, tempWorld chan<- [][]byte) {
-	worldPart := calculateNextState(startY, endY, startX, endX, p, world, c)
-	tempWorld <- worldPart
+func worker(t tile, cells [][]byte, p Params, world [][]byte, c distributorChannels, tempWorld chan<- strip) {
+	start := time.Now()
+	region := trace.StartRegion(c.ctx, workerRegion)
+	calculateNextStateInto(cells, t.startY, t.endY, t.startX, t.endX, p, world, c)
+	region.End()
+	if faultInjector != nil {
+		faultInjector(t, cells)
+	}
+	tempWorld <- strip{
+		tile:     t,
+		cells:    cells,
+		checksum: stripChecksum(world, t, cells),
+		alive:    countAlive(cells),
+		duration: time.Since(start),
+	}
+}
+
+// channelWorkers run the Channels mode, starting a worker for each tile every turn and
+// collecting the strips they send back. The channels and each tile's rows are kept
+// between turns.
+type channelWorkers struct {
+	tiles     []tile
+	tempWorld []chan strip
+	cells     [][][]byte
+	strips    []strip
+}
+
+func newChannelWorkers(tiles []tile) *channelWorkers {
+	w := &channelWorkers{
+		tiles:     tiles,
+		tempWorld: make([]chan strip, len(tiles)),
+		cells:     make([][][]byte, len(tiles)),
+		strips:    make([]strip, len(tiles)),
+	}
+	for i, t := range tiles {
+		w.tempWorld[i] = make(chan strip, 1)
+		w.cells[i] = make([][]byte, t.height())
+	}
+	return w
+}
+
+// step computes the turn after world, with every worker writing its tile into next.
+func (w *channelWorkers) step(p Params, world, next [][]byte, c distributorChannels) []strip {
+	for i, t := range w.tiles {
+		cells := w.cells[i]
+		for y := range cells {
+			cells[y] = next[t.startY+y][t.startX:t.endX]
+		}
+		go worker(t, cells, p, world, c, w.tempWorld[i])
+	}
+	for i := range w.strips {
+		w.strips[i] = <-w.tempWorld[i]
+		w.strips[i].worker = i
+	}
+	return w.strips
+}
+
+func countAlive(cells [][]byte) int {
+	alive := 0
+	for _, row := range cells {
+		for _, cell := range row {
+			if cell == 255 {
+				alive++
+			}
+		}
+	}
+	return alive
 }
 
 // send the world into output
 func outputImage(c distributorChannels, p Params, world [][]byte) {
+	start := time.Now()
 	c.ioCommand <- ioOutput
 	filename := strings.Join([]string{strconv.Itoa(p.ImageHeight), strconv.Itoa(p.ImageWidth), strconv.Itoa(c.completedTurns)}, "x")
 	c.ioFilename <- filename
@@ -36,30 +113,154 @@ func outputImage(c distributorChannels, p Params, world [][]byte) {
 	}
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
+	c.metrics.IO("output", time.Since(start))
+	c.events <- ImageOutputComplete{c.completedTurns, filename}
+
+}
+
+// outputPlane sends the bounding box of the plane to the io goroutine as an image named
+// after the box's size and the turn.
+func outputPlane(c distributorChannels, plane *sparseWorld) {
+	box := plane.bounds()
+	world := plane.dense(box)
+	start := time.Now()
+	c.ioCommand <- ioOutputSized
+	filename := strings.Join([]string{strconv.Itoa(box.height()), strconv.Itoa(box.width()), strconv.Itoa(c.completedTurns)}, "x")
+	c.ioFilename <- filename
+	c.ioSize <- [2]int{box.width(), box.height()}
+	for _, row := range world {
+		for _, cell := range row {
+			c.ioOutput <- cell
+		}
+	}
+	c.ioCommand <- ioCheckIdle
+	<-c.ioIdle
+	c.metrics.IO("output", time.Since(start))
 	c.events <- ImageOutputComplete{c.completedTurns, filename}
+}
 
+// outputDisk writes an out-of-core world straight from its files, as an RLE pattern if
+// p.RLE is set and otherwise as a PGM image.
+func outputDisk(c distributorChannels, p Params, disk *diskWorld) {
+	start := time.Now()
+	filename := strings.Join([]string{strconv.Itoa(p.ImageHeight), strconv.Itoa(p.ImageWidth), strconv.Itoa(c.completedTurns)}, "x")
+	write, extension := disk.writePgm, ".pgm"
+	if p.RLE {
+		write, extension = disk.writeRle, ".rle"
+	}
+	_ = os.MkdirAll(c.outDir, os.ModePerm)
+	file, err := os.Create(filepath.Join(c.outDir, filename+extension))
+	util.Check(err)
+	defer file.Close()
+	region := trace.StartRegion(c.ctx, outputRegion)
+	util.Check(write(bufio.NewWriterSize(file, 1<<20)))
+	region.End()
+	fmt.Println("File", filename, "output done!")
+	c.metrics.IO("output", time.Since(start))
+	c.events <- ImageOutputComplete{c.completedTurns, filename}
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p Params, c distributorChannels) {
-
-	// Create a 2D slice to store the world.
-	world := initWorld(p.ImageHeight, p.ImageWidth)
+	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	// It is deferred first so that it happens last, once the workers and files are cleaned up.
+	defer close(c.events)
 
 	ticker := time.NewTicker(2 * time.Second)
 
-	c.ioCommand <- ioInput
-	c.ioFilename <- strings.Join([]string{strconv.Itoa(p.ImageHeight), strconv.Itoa(p.ImageWidth)}, "x")
-	// add value to the input
-	for y := 0; y < p.ImageHeight; y++ {
-		for x := 0; x < p.ImageWidth; x++ {
-			val := <-c.ioInput
-			world[y][x] = val
-			if val == 255 {
-				c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+	// An out-of-core game keeps its world in files, and never holds it all in memory.
+	var world [][]byte
+	var disk *diskWorld
+	inputStart := time.Now()
+	if p.OutOfCore != "" {
+		disk = loadDiskWorld(p)
+		defer disk.close()
+	} else {
+		// Create a 2D slice to store the world.
+		world = initWorld(p.ImageHeight, p.ImageWidth)
+
+		c.ioCommand <- ioInput
+		c.ioFilename <- strings.Join([]string{strconv.Itoa(p.ImageHeight), strconv.Itoa(p.ImageWidth)}, "x")
+		// add value to the input
+		for y := 0; y < p.ImageHeight; y++ {
+			for x := 0; x < p.ImageWidth; x++ {
+				val := <-c.ioInput
+				world[y][x] = val
+				if val == 255 {
+					c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+				}
 			}
 		}
 	}
+	c.metrics.IO("input", time.Since(inputStart))
+
+	var tune *tuner
+	if p.AutoThreads {
+		tune = newTuner(tuneCandidates(runtime.NumCPU(), p.ImageWidth*p.ImageHeight))
+		p.Threads = tune.threads()
+	}
+	// Out-of-core and unbounded games step their own worlds instead of using an engine.
+	// An unbounded game keeps its cells on a sparse plane.
+	var engine *engine
+	var plane *sparseWorld
+	switch {
+	case disk != nil:
+	case p.Unbounded:
+		plane = newSparseWorld(world)
+		world = nil
+	default:
+		engine = newEngine(p, c)
+	}
+	defer func() {
+		if engine != nil {
+			engine.stop()
+		}
+	}()
+	c.metrics.Threads(p.Threads)
+
+	// setThreads replaces the engine with one for n workers, between two turns.
+	setThreads := func(n int) {
+		if n < 1 || n > p.ImageWidth*p.ImageHeight || n == p.Threads {
+			return
+		}
+		p.Threads = n
+		if engine != nil {
+			engine.stop()
+			engine = newEngine(p, c)
+		}
+		c.metrics.Threads(n)
+		c.events <- ThreadsChanged{c.completedTurns, n}
+	}
+
+	aliveCells := func() []util.Cell {
+		switch {
+		case disk != nil:
+			return disk.aliveCells()
+		case plane != nil:
+			return plane.aliveCells()
+		}
+		return calculateAliveCells(p, world)
+	}
+	aliveCount := func() int {
+		switch {
+		case disk != nil:
+			return disk.alive
+		case plane != nil:
+			return plane.count()
+		}
+		return len(calculateAliveCells(p, world))
+	}
+	// output writes the world, or the bounding box of the plane's alive cells.
+	output := func() {
+		switch {
+		case disk != nil:
+			outputDisk(c, p, disk)
+		case plane != nil:
+			outputPlane(c, plane)
+		default:
+			outputImage(c, p, world)
+		}
+	}
 
 	turn := 0
 	c.events <- StateChange{turn, Executing}
@@ -67,28 +268,27 @@ func distributor(p Params, c distributorChannels) {
 	// Execute all turns of the Game of Life.
 	for turn = 0; turn < p.Turns; turn++ {
 		c.completedTurns = turn + 1
-
-		if p.Threads == 1 {
-			world = calculateNextState(0, p.ImageHeight, 0, p.ImageWidth, p, world, c)
-		} else {
-			tempWorld := make([]chan [][]byte, p.Threads)
-			for i := range tempWorld {
-				tempWorld[i] = make(chan [][]byte)
-			}
-
-			heightPerThread := p.ImageHeight / p.Threads
-
-			for i := 0; i < p.Threads-1; i++ {
-				go worker(i*heightPerThread, (i+1)*heightPerThread, 0, p.ImageWidth, p, world, c, tempWorld[i])
-			}
-			go worker((p.Threads-1)*heightPerThread, p.ImageHeight, 0, p.ImageWidth, p, world, c, tempWorld[p.Threads-1])
-
-			mergeWorld := initWorld(0, 0)
-			for i := 0; i < p.Threads; i++ {
-				pieces := <-tempWorld[i]
-				mergeWorld = append(mergeWorld, pieces...)
-			}
-			world = mergeWorld
+		turnStart := time.Now()
+		region := trace.StartRegion(c.ctx, turnRegion)
+		if trace.IsEnabled() {
+			trace.Logf(c.ctx, "turn", "%v", c.completedTurns)
+		}
+		var workerTimes []time.Duration
+		var alive int
+		switch {
+		case disk != nil:
+			workerTimes, alive = disk.step(p, c)
+		case plane != nil:
+			plane, workerTimes, alive = plane.step(p, c)
+		default:
+			world, workerTimes, alive = engine.step(world, c.completedTurns)
+		}
+		region.End()
+		latency := time.Since(turnStart)
+		c.metrics.Turn(latency, workerTimes, alive)
+		if tune != nil {
+			tune.observe(time.Now(), latency)
+			setThreads(tune.threads())
 		}
 
 		c.events <- TurnComplete{CompletedTurns: c.completedTurns}
@@ -96,19 +296,25 @@ func distributor(p Params, c distributorChannels) {
 		select {
 		// ticker.C is a channel that receives ticks every 2 seconds
 		case <-ticker.C:
-			c.events <- AliveCellsCount{c.completedTurns, len(calculateAliveCells(p, world))}
+			c.events <- AliveCellsCount{c.completedTurns, aliveCount()}
 		case key := <-c.keyPresses:
 			switch key {
+			// Choosing the count by hand stops it being tuned.
+			case '+':
+				tune = nil
+				setThreads(p.Threads + 1)
+			case '-':
+				tune = nil
+				setThreads(p.Threads - 1)
 			case 's':
 				c.events <- StateChange{c.completedTurns, Executing}
-				outputImage(c, p, world)
+				output()
 			case 'q':
-				outputImage(c, p, world)
+				output()
 				c.ioCommand <- ioCheckIdle
 				<-c.ioIdle
-				c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: calculateAliveCells(p, world)}
+				c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: aliveCells()}
 				c.events <- StateChange{turn, Quitting}
-				close(c.events)
 				return
 			case 'p':
 				c.events <- StateChange{turn, Paused}
@@ -120,15 +326,20 @@ func distributor(p Params, c distributorChannels) {
 					case 'p':
 						c.events <- StateChange{turn, Executing}
 						pause = false
+					case '+':
+						tune = nil
+						setThreads(p.Threads + 1)
+					case '-':
+						tune = nil
+						setThreads(p.Threads - 1)
 					case 's':
-						outputImage(c, p, world)
+						output()
 					case 'q':
-						outputImage(c, p, world)
+						output()
 						c.ioCommand <- ioCheckIdle
 						<-c.ioIdle
-						c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: calculateAliveCells(p, world)}
+						c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: aliveCells()}
 						c.events <- StateChange{turn, Quitting}
-						close(c.events)
 						return
 					}
 				}
@@ -138,16 +349,13 @@ func distributor(p Params, c distributorChannels) {
 
 	}
 
-	outputImage(c, p, world)
+	output()
 
 	// Report the final state using FinalTurnCompleteEvent.
-	c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: calculateAliveCells(p, world)}
+	c.events <- FinalTurnComplete{CompletedTurns: c.completedTurns, Alive: aliveCells()}
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
 
 	c.events <- StateChange{c.completedTurns, Quitting}
-
-	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
-	close(c.events)
 }
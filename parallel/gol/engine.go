@@ -0,0 +1,89 @@
+package gol
+
+import "time"
+
+// engine computes turns in the chosen Mode. The world is double-buffered: every turn is
+// written in place into the buffer that held the turn before, so in steady state stepping
+// allocates nothing. The Channels mode still allocates to start its workers each turn,
+// and CellFlipped events allocate when they are sent.
+type engine struct {
+	p           Params
+	c           distributorChannels
+	spare       [][]byte // the buffer the next turn is written into, in the Channels mode
+	channels    *channelWorkers
+	shared      *sharedWorkers
+	stealing    *stealingWorkers
+	workerTimes []time.Duration
+}
+
+func newEngine(p Params, c distributorChannels) *engine {
+	e := &engine{p: p, c: c}
+	tiles := planTiles(p.ImageWidth, p.ImageHeight, p.Threads)
+	switch p.Mode {
+	case Shared:
+		e.shared = newSharedWorkers(p, tiles, c)
+	case Stealing:
+		e.stealing = newStealingWorkers(p, c)
+	default:
+		e.spare = initWorld(p.ImageHeight, p.ImageWidth)
+		// A single thread without verification computes the whole world itself, without a worker or a checksum.
+		if p.Threads > 1 || p.VerifyRate > 0 {
+			e.channels = newChannelWorkers(tiles)
+		}
+	}
+	return e
+}
+
+// step computes the turn after world. It returns the next world, how long each worker spent
+// on it and how many of its cells are alive. The engine takes world as its spare buffer, so
+// the caller must stop using world once it has the next one. The returned slice of worker
+// times is reused by the following step.
+func (e *engine) step(world [][]byte, completedTurns int) ([][]byte, []time.Duration, int) {
+	e.c.completedTurns = completedTurns
+	var next [][]byte
+	var strips []strip
+	switch {
+	case e.shared != nil:
+		// The previous world stays intact until the next step, so it can still be verified against.
+		next, strips = e.shared.step(world, completedTurns)
+	case e.stealing != nil:
+		next, strips = e.stealing.step(world, completedTurns)
+	case e.channels != nil:
+		next = e.spare
+		strips = e.channels.step(e.p, world, next, e.c)
+	default:
+		start := time.Now()
+		next = e.spare
+		calculateNextStateInto(next, 0, e.p.ImageHeight, 0, e.p.ImageWidth, e.p, world, e.c)
+		e.spare = world
+		e.workerTimes = append(e.workerTimes[:0], time.Since(start))
+		return next, e.workerTimes, countAlive(next)
+	}
+	if e.p.VerifyRate > 0 {
+		verifyStrips(strips, e.p, world, e.c)
+	}
+	if e.channels != nil {
+		e.spare = world
+	}
+
+	e.workerTimes = e.workerTimes[:0]
+	alive := 0
+	for _, s := range strips {
+		for len(e.workerTimes) <= s.worker {
+			e.workerTimes = append(e.workerTimes, 0)
+		}
+		e.workerTimes[s.worker] += s.duration
+		alive += s.alive
+	}
+	return next, e.workerTimes, alive
+}
+
+// stop ends any workers that live for the whole game. It must not be called during a step.
+func (e *engine) stop() {
+	switch {
+	case e.shared != nil:
+		e.shared.stop()
+	case e.stealing != nil:
+		e.stealing.stop()
+	}
+}
@@ -0,0 +1,92 @@
+package gol
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestEngineAllocs steps a random soup until it is warmed up and checks that further turns
+// allocate nothing in any mode or kernel, apart from starting the workers in the Channels mode.
+func TestEngineAllocs(t *testing.T) {
+	for _, mode := range []Mode{Channels, Shared, Stealing} {
+		for _, threads := range []int{1, 4, 16} {
+			for _, kernel := range []Kernel{CellKernel, BlockKernel} {
+				p := Params{Threads: threads, ImageWidth: 64, ImageHeight: 64, Mode: mode, Kernel: kernel}
+				t.Run(fmt.Sprintf("%v-%d-%v", p.Mode, p.Threads, p.Kernel), func(t *testing.T) {
+					world := initWorld(p.ImageHeight, p.ImageWidth)
+					random := rand.New(rand.NewSource(1))
+					for _, row := range world {
+						for x := range row {
+							if random.Intn(3) == 0 {
+								row[x] = 255
+							}
+						}
+					}
+					// No events are sent, as boxing each CellFlipped into an Event allocates.
+					e := newEngine(p, distributorChannels{ctx: context.Background()})
+					defer e.stop()
+					turn := 0
+					step := func() {
+						turn++
+						world, _, _ = e.step(world, turn)
+					}
+					for i := 0; i < 10; i++ {
+						step()
+					}
+
+					allowed := 0.0
+					if e.channels != nil {
+						allowed = float64(len(e.channels.tiles))
+					}
+					if allocs := testing.AllocsPerRun(100, step); allocs > allowed {
+						t.Errorf("ERROR: Expected at most %v allocations per turn, got %v", allowed, allocs)
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestEngineMatchesReference checks the double-buffered engine against calculateNextState,
+// which allocates a new world every turn, in every mode.
+func TestEngineMatchesReference(t *testing.T) {
+	for _, mode := range []Mode{Channels, Shared, Stealing} {
+		for _, threads := range []int{1, 3, 8} {
+			p := Params{Threads: threads, ImageWidth: 48, ImageHeight: 32, Mode: mode}
+			t.Run(fmt.Sprintf("%v-%d", p.Mode, p.Threads), func(t *testing.T) {
+				world := initWorld(p.ImageHeight, p.ImageWidth)
+				random := rand.New(rand.NewSource(2))
+				for _, row := range world {
+					for x := range row {
+						if random.Intn(3) == 0 {
+							row[x] = 255
+						}
+					}
+				}
+				reference := initWorld(p.ImageHeight, p.ImageWidth)
+				for y := range world {
+					copy(reference[y], world[y])
+				}
+
+				c := distributorChannels{ctx: context.Background()}
+				e := newEngine(p, c)
+				defer e.stop()
+				for turn := 1; turn <= 50; turn++ {
+					var alive int
+					world, _, alive = e.step(world, turn)
+					reference = calculateNextState(0, p.ImageHeight, 0, p.ImageWidth, p, reference, c)
+					for y := range world {
+						if string(world[y]) != string(reference[y]) {
+							t.Fatalf("ERROR: Row %v differs from the reference after turn %v", y, turn)
+						}
+					}
+					if expected := countAlive(reference); alive != expected {
+						t.Fatalf("ERROR: Counted %v alive cells after turn %v, expected %v", alive, turn, expected)
+					}
+				}
+			})
+		}
+	}
+}
@@ -78,6 +78,26 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// `StripMismatch` is an Event notifying the user that a worker's strip did not match its
+// recomputation by another worker when verifying checksums. The recomputed strip is used instead.
+// This Event is only sent when `Params.VerifyRate` is set.
+type StripMismatch struct { // implements Event
+	CompletedTurns int
+	StartX         int
+	EndX           int
+	StartY         int
+	EndY           int
+}
+
+// `ThreadsChanged` is an Event notifying the user that the number of workers has changed.
+// This Event is sent when '+' or '-' is pressed, and the new count is used from the next turn.
+// With Params.AutoThreads it is also sent for every count the tuner tries, so viewers see a
+// burst of them each time the tuner samples, ending with the count it settles on.
+type ThreadsChanged struct { // implements Event
+	CompletedTurns int
+	Threads        int
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -149,6 +169,22 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event StripMismatch) String() string {
+	return fmt.Sprintf("Strip rows %v-%v columns %v-%v failed verification and was recomputed", event.StartY, event.EndY, event.StartX, event.EndX)
+}
+
+func (event StripMismatch) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event ThreadsChanged) String() string {
+	return fmt.Sprintf("Threads %v", event.Threads)
+}
+
+func (event ThreadsChanged) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -52,14 +52,23 @@ func countLiveNeighbors(world [][]byte, row, col, rows, cols int) int {
 	return liveNeighbors
 }
 
+// calculateNextState computes rows [startY, endY) of the next turn.
+// CellFlipped events are only sent if c.events is not nil.
 func calculateNextState(startY, endY, startX, endX int, p Params, world [][]byte, c distributorChannels) [][]byte {
-	height := endY - startY
-	width := endX - startX
+	newWorld := initWorld(endY-startY, endX-startX)
+	calculateNextStateInto(newWorld, startY, endY, startX, endX, p, world, c)
+	return newWorld
+}
 
-	newWorld := make([][]byte, height)
-	for i := range newWorld {
-		newWorld[i] = make([]byte, width)
+// calculateNextStateInto is calculateNextState writing into newWorld, which must
+// have endY-startY rows of endX-startX cells. It returns how many cells flipped.
+func calculateNextStateInto(newWorld [][]byte, startY, endY, startX, endX int, p Params, world [][]byte, c distributorChannels) int {
+	if p.Kernel == BlockKernel {
+		return calculateNextBlocksInto(newWorld, startY, endY, startX, endX, p, world, c)
 	}
+	height := endY - startY
+	width := endX - startX
+	flips := 0
 
 	// Iterate over each cell in the world
 	for y := 0; y < height; y++ {
@@ -74,7 +83,10 @@ func calculateNextState(startY, endY, startX, endX int, p Params, world [][]byte
 				// Cell is alive
 				if liveNeighbors < 2 || liveNeighbors > 3 {
 					newWorld[y][x] = 0 // Cell dies
-					c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: globalX, Y: globalY}}
+					flips++
+					if c.events != nil {
+						c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: globalX, Y: globalY}}
+					}
 				} else {
 					newWorld[y][x] = 255 // Cell stays alive
 				}
@@ -82,12 +94,15 @@ func calculateNextState(startY, endY, startX, endX int, p Params, world [][]byte
 				// Cell is dead
 				if liveNeighbors == 3 {
 					newWorld[y][x] = 255 // Cell becomes alive
-					c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: globalX, Y: globalY}}
+					flips++
+					if c.events != nil {
+						c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: globalX, Y: globalY}}
+					}
 				} else {
 					newWorld[y][x] = 0 // Cell stays dead
 				}
 			}
 		}
 	}
-	return newWorld
+	return flips
 }
@@ -1,15 +1,65 @@
 package gol
 
+import (
+	"context"
+	"runtime/trace"
+
+	"uk.ac.bris.cs/gameoflife/metrics"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// VerifyRate is the fraction of strips recomputed by a second worker each turn to check their checksums.
+	VerifyRate float64
+	// Mode selects how workers share the world. The zero value is Channels.
+	Mode Mode
+	// Kernel selects how workers compute their cells. The zero value is CellKernel.
+	Kernel Kernel
+	// AutoThreads lets the game choose Threads itself, trying several counts and settling on the fastest.
+	// Each count it tries is announced with a ThreadsChanged event.
+	AutoThreads bool
+	// Unbounded plays on an infinite plane instead of wrapping around the edges of the image.
+	// Snapshots and images then cover the smallest rectangle holding every alive cell.
+	Unbounded bool
+	// OutOfCore is a directory to keep the world in, bit-packed in files, for worlds too big
+	// for memory. The image may be an RLE pattern instead of a PGM image. Empty keeps the
+	// world in memory. CellFlipped events are not sent for out-of-core games.
+	OutOfCore string
+	// RLE writes out-of-core images as RLE patterns instead of PGM images.
+	RLE bool
 }
 
+// Names of the task and regions in execution traces. They contain spaces so that they
+// cannot be confused with the function names in a trace's stacks.
+const (
+	runTask      = "gol: run"
+	turnRegion   = "gol: turn"
+	workerRegion = "gol: worker"
+	inputRegion  = "gol: input"
+	outputRegion = "gol: output"
+	verifyRegion = "gol: verify"
+)
+
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	run(p, events, keyPresses, runOptions{metrics: metrics.Default, outDir: "out"})
+}
+
+// runOptions are what a game shares with the rest of the program. Games other than the
+// main one, such as service jobs, use their own so as to leave the main game's alone.
+type runOptions struct {
+	metrics *metrics.Metrics // where the game's performance is recorded
+	outDir  string           // where images are written
+}
+
+func run(p Params, events chan<- Event, keyPresses <-chan rune, opts runOptions) {
+	// The task groups this game's turn, worker and IO regions in execution traces.
+	ctx, task := trace.NewTask(context.Background(), runTask)
+	defer task.End()
 
 	// Put the missing channels in here.
 	ioCommand := make(chan ioCommand)
@@ -17,6 +67,7 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 	ioFilename := make(chan string)
 	ioOutput := make(chan uint8)
 	ioInput := make(chan uint8)
+	ioSize := make(chan [2]int)
 
 	completedTurns := 0
 
@@ -26,8 +77,9 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: ioFilename,
 		output:   ioOutput,
 		input:    ioInput,
+		size:     ioSize,
 	}
-	go startIo(p, ioChannels)
+	go startIo(ctx, p, ioChannels, opts.outDir)
 
 	distributorChannels := distributorChannels{
 		events:         events,
@@ -36,8 +88,12 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioFilename:     ioFilename,
 		ioOutput:       ioOutput,
 		ioInput:        ioInput,
+		ioSize:         ioSize,
 		completedTurns: completedTurns,
 		keyPresses:     keyPresses,
+		ctx:            ctx,
+		metrics:        opts.metrics,
+		outDir:         opts.outDir,
 	}
 	distributor(p, distributorChannels)
 }
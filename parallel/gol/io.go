@@ -1,8 +1,11 @@
 package gol
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime/trace"
 	"strconv"
 	"strings"
 
@@ -16,12 +19,14 @@ type ioChannels struct {
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
+	size     <-chan [2]int
 }
 
 // ioState is the internal ioState of the io goroutine.
 type ioState struct {
 	params   Params
 	channels ioChannels
+	outDir   string
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -33,39 +38,52 @@ type ioCommand uint8
 //	ioOutput 	= 0
 //	ioInput 	= 1
 //	ioCheckIdle = 2
+//	ioOutputSized = 3
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	// ioOutputSized writes an image whose width and height are sent after its filename.
+	ioOutputSized
 )
 
 // writePgmImage receives an array of bytes and writes it to a pgm file.
 func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
-
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
+	io.writeSizedPgmImage(filename, io.params.ImageWidth, io.params.ImageHeight)
+}
+
+// writeBoundsImage is writePgmImage for an image that is not the size of the world.
+func (io *ioState) writeBoundsImage() {
+	filename := <-io.channels.filename
+	size := <-io.channels.size
+	io.writeSizedPgmImage(filename, size[0], size[1])
+}
+
+func (io *ioState) writeSizedPgmImage(filename string, width, height int) {
+	_ = os.MkdirAll(io.outDir, os.ModePerm)
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
+	file, ioError := os.Create(filepath.Join(io.outDir, filename+".pgm"))
 	util.Check(ioError)
 	defer file.Close()
 
 	_, _ = file.WriteString("P5\n")
 	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
+	_, _ = file.WriteString(strconv.Itoa(width))
 	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
+	_, _ = file.WriteString(strconv.Itoa(height))
 	_, _ = file.WriteString("\n")
 	_, _ = file.WriteString(strconv.Itoa(255))
 	_, _ = file.WriteString("\n")
 
-	world := make([][]byte, io.params.ImageHeight)
+	world := make([][]byte, height)
 	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+		world[i] = make([]byte, width)
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
 			val := <-io.channels.output
 			//if val != 0 {
 			//	fmt.Println(x, y)
@@ -74,8 +92,8 @@ func (io *ioState) writePgmImage() {
 		}
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
 			_, ioError = file.Write([]byte{world[y][x]})
 			util.Check(ioError)
 		}
@@ -126,20 +144,23 @@ func (io *ioState) readPgmImage() {
 	fmt.Println("File", filename, "input done!")
 }
 
-// startIo should be the entrypoint of the io goroutine.
-func startIo(p Params, c ioChannels) {
+// startIo should be the entrypoint of the io goroutine. Images are written to outDir.
+func startIo(ctx context.Context, p Params, c ioChannels, outDir string) {
 	io := ioState{
 		params:   p,
 		channels: c,
+		outDir:   outDir,
 	}
 
 	for command := range io.channels.command {
 		// Block and wait for requests from the distributor
 		switch command {
 		case ioInput:
-			io.readPgmImage()
+			trace.WithRegion(ctx, inputRegion, io.readPgmImage)
 		case ioOutput:
-			io.writePgmImage()
+			trace.WithRegion(ctx, outputRegion, io.writePgmImage)
+		case ioOutputSized:
+			trace.WithRegion(ctx, outputRegion, io.writeBoundsImage)
 		case ioCheckIdle:
 			io.channels.idle <- true
 		}
@@ -0,0 +1,121 @@
+package gol
+
+import (
+	"fmt"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Kernel selects how workers compute the next state of their cells.
+type Kernel int
+
+const (
+	// CellKernel counts the neighbours of every cell on its own.
+	CellKernel Kernel = iota
+	// BlockKernel looks up the next state of 2x2 blocks of cells in a table indexed by
+	// the 4x4 neighbourhood around each block.
+	BlockKernel
+)
+
+func (kernel Kernel) String() string {
+	switch kernel {
+	case CellKernel:
+		return "cells"
+	case BlockKernel:
+		return "blocks"
+	default:
+		return "Incorrect Kernel"
+	}
+}
+
+// ParseKernel reads a Kernel from its name, as used by the -kernel flag.
+func ParseKernel(s string) (Kernel, error) {
+	for _, kernel := range []Kernel{CellKernel, BlockKernel} {
+		if s == kernel.String() {
+			return kernel, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown kernel %q, expected cells or blocks", s)
+}
+
+// blockTable maps a 4x4 neighbourhood to the next state of the 2x2 block in its middle.
+// The neighbourhood is four columns of four bits, left column in the highest bits, and
+// each column has its top cell in the highest bit. The result has the top left cell in
+// bit 0, then top right, bottom left and bottom right.
+var blockTable = makeBlockTable()
+
+func makeBlockTable() []byte {
+	table := make([]byte, 1<<16)
+	alive := func(index, x, y int) int {
+		return index >> ((3-x)*4 + 3 - y) & 1
+	}
+	for index := range table {
+		var next byte
+		for bit, cell := range [4][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}} {
+			x, y := cell[0], cell[1]
+			neighbours := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if dx != 0 || dy != 0 {
+						neighbours += alive(index, x+dx, y+dy)
+					}
+				}
+			}
+			if neighbours == 3 || neighbours == 2 && alive(index, x, y) == 1 {
+				next |= 1 << bit
+			}
+		}
+		table[index] = next
+	}
+	return table
+}
+
+// calculateNextBlocksInto is calculateNextStateInto using the BlockKernel. Blocks start at
+// the tile's top left corner, and where a tile has an odd width or height the last blocks
+// hang over its edge and only the cells inside it are written.
+func calculateNextBlocksInto(newWorld [][]byte, startY, endY, startX, endX int, p Params, world [][]byte, c distributorChannels) int {
+	flips := 0
+	for y := startY; y < endY; y += 2 {
+		// The four rows around the block, wrapping around the edges of the world.
+		rows := [4][]byte{
+			world[wrap(y-1, p.ImageHeight)],
+			world[wrap(y, p.ImageHeight)],
+			world[wrap(y+1, p.ImageHeight)],
+			world[wrap(y+2, p.ImageHeight)],
+		}
+		column := func(x int) int {
+			x = wrap(x, p.ImageWidth)
+			return int(rows[0][x]&1)<<3 | int(rows[1][x]&1)<<2 | int(rows[2][x]&1)<<1 | int(rows[3][x]&1)
+		}
+		// Slide along the rows two columns at a time, keeping the columns already read.
+		left, middle := column(startX-1), column(startX)
+		for x := startX; x < endX; x += 2 {
+			right, far := column(x+1), column(x+2)
+			next := blockTable[left<<12|middle<<8|right<<4|far]
+			for bit := 0; bit < 4; bit++ {
+				cellX, cellY := x+bit%2, y+bit/2
+				if cellX >= endX || cellY >= endY {
+					continue
+				}
+				cell := byte(0)
+				if next>>bit&1 == 1 {
+					cell = 255
+				}
+				newWorld[cellY-startY][cellX-startX] = cell
+				if cell != world[cellY][cellX] {
+					flips++
+					if c.events != nil {
+						c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: cellX, Y: cellY}}
+					}
+				}
+			}
+			left, middle = right, far
+		}
+	}
+	return flips
+}
+
+// wrap returns i wrapped into [0, n). Blocks can reach twice around worlds under three cells wide.
+func wrap(i, n int) int {
+	return (i%n + n) % n
+}
@@ -0,0 +1,84 @@
+package gol
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestBlockKernel checks the BlockKernel against the CellKernel on random worlds, including
+// odd and tiny ones, for every tile of several plans so that blocks hang over tile edges.
+func TestBlockKernel(t *testing.T) {
+	sizes := [][2]int{{1, 1}, {2, 3}, {3, 2}, {5, 7}, {13, 9}, {16, 16}, {64, 64}, {37, 91}}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		world := initWorld(height, width)
+		random := rand.New(rand.NewSource(int64(width * height)))
+		for _, row := range world {
+			for x := range row {
+				if random.Intn(3) == 0 {
+					row[x] = 255
+				}
+			}
+		}
+		for _, threads := range []int{1, 2, 3, 7, 16} {
+			t.Run(fmt.Sprintf("%dx%d-%d", width, height, threads), func(t *testing.T) {
+				cellParams := Params{ImageWidth: width, ImageHeight: height}
+				blockParams := cellParams
+				blockParams.Kernel = BlockKernel
+				for _, tile := range planTiles(width, height, threads) {
+					cellEvents := make(chan Event, width*height)
+					blockEvents := make(chan Event, width*height)
+					cells := initWorld(tile.height(), tile.width())
+					blocks := initWorld(tile.height(), tile.width())
+					cellFlips := calculateNextStateInto(cells, tile.startY, tile.endY, tile.startX, tile.endX, cellParams, world, distributorChannels{events: cellEvents})
+					blockFlips := calculateNextStateInto(blocks, tile.startY, tile.endY, tile.startX, tile.endX, blockParams, world, distributorChannels{events: blockEvents})
+					close(cellEvents)
+					close(blockEvents)
+
+					for y := range cells {
+						if string(cells[y]) != string(blocks[y]) {
+							t.Fatalf("ERROR: Tile %+v row %v is %v, expected %v", tile, y, blocks[y], cells[y])
+						}
+					}
+					if blockFlips != cellFlips || len(blockEvents) != len(cellEvents) {
+						t.Fatalf("ERROR: Tile %+v flipped %v cells with %v events, expected %v with %v", tile, blockFlips, len(blockEvents), cellFlips, len(cellEvents))
+					}
+					flipped := make(map[Event]bool)
+					for e := range cellEvents {
+						flipped[e] = true
+					}
+					for e := range blockEvents {
+						if !flipped[e] {
+							t.Fatalf("ERROR: Unexpected %v", e)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkKernel compares the kernels on a 512x512 random soup computed by one worker.
+func BenchmarkKernel(b *testing.B) {
+	const size = 512
+	world := initWorld(size, size)
+	random := rand.New(rand.NewSource(1))
+	for _, row := range world {
+		for x := range row {
+			if random.Intn(3) == 0 {
+				row[x] = 255
+			}
+		}
+	}
+	next := initWorld(size, size)
+	for _, kernel := range []Kernel{CellKernel, BlockKernel} {
+		p := Params{ImageWidth: size, ImageHeight: size, Kernel: kernel}
+		b.Run(fmt.Sprintf("kernel=%v", kernel), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				calculateNextStateInto(next, 0, size, 0, size, p, world, distributorChannels{ctx: context.Background()})
+			}
+		})
+	}
+}
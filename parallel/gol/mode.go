@@ -0,0 +1,41 @@
+package gol
+
+import "fmt"
+
+// Mode selects how workers share the world and hand back their results.
+type Mode int
+
+const (
+	// Channels starts a worker per tile each turn, which sends its strip back over a channel.
+	Channels Mode = iota
+	// Shared keeps one worker per thread for the whole game. Workers write straight into a
+	// common buffer for the next world and meet the distributor at a barrier every turn.
+	Shared
+	// Stealing keeps one worker per thread like Shared, but cuts the world into many small tiles
+	// dealt out by their activity in the previous turn. Idle workers steal tiles from busy ones.
+	// It is meant for worlds whose activity is uneven.
+	Stealing
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case Channels:
+		return "channels"
+	case Shared:
+		return "shared"
+	case Stealing:
+		return "stealing"
+	default:
+		return "Incorrect Mode"
+	}
+}
+
+// ParseMode reads a Mode from its name, as used by the -mode flag.
+func ParseMode(s string) (Mode, error) {
+	for _, mode := range []Mode{Channels, Shared, Stealing} {
+		if s == mode.String() {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode %q, expected channels, shared or stealing", s)
+}
@@ -0,0 +1,207 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rleLineLength is the longest line written in an RLE file, as the format recommends.
+const rleLineLength = 70
+
+// rleWriter writes a pattern in the RLE format used by most Life programs. Runs are
+// buffered so that dead cells at the end of a row and empty rows cost nothing.
+type rleWriter struct {
+	w       *bufio.Writer
+	line    int  // length of the current line
+	tag     byte // tag of the run being built, or 0 if there is none
+	count   int
+	newRows int // row ends not yet written
+	err     error
+}
+
+func newRleWriter(w *bufio.Writer, width, height int) *rleWriter {
+	r := &rleWriter{w: w}
+	_, r.err = fmt.Fprintf(w, "x = %v, y = %v, rule = B3/S23\n", width, height)
+	return r
+}
+
+// cells adds a run of n cells to the current row.
+func (r *rleWriter) cells(alive bool, n int) {
+	tag := byte('b')
+	if alive {
+		tag = 'o'
+	}
+	if r.tag == tag {
+		r.count += n
+		return
+	}
+	r.flush()
+	r.tag, r.count = tag, n
+}
+
+// endRow ends the current row. Dead cells at its end are dropped.
+func (r *rleWriter) endRow() {
+	if r.tag == 'b' {
+		r.tag = 0
+	}
+	r.flush()
+	r.newRows++
+}
+
+func (r *rleWriter) flush() {
+	if r.newRows > 0 && r.tag != 0 {
+		r.item(r.newRows, '$')
+		r.newRows = 0
+	}
+	if r.tag != 0 {
+		r.item(r.count, r.tag)
+		r.tag = 0
+	}
+}
+
+func (r *rleWriter) item(count int, tag byte) {
+	s := string(tag)
+	if count > 1 {
+		s = strconv.Itoa(count) + s
+	}
+	if r.line+len(s) > rleLineLength {
+		r.write("\n")
+		r.line = 0
+	}
+	r.write(s)
+	r.line += len(s)
+}
+
+func (r *rleWriter) write(s string) {
+	if r.err == nil {
+		_, r.err = r.w.WriteString(s)
+	}
+}
+
+// close ends the pattern. Empty rows at its end are dropped.
+func (r *rleWriter) close() error {
+	r.flush()
+	r.write("!\n")
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+// RleReader reads a pattern in the RLE format a row at a time, so that patterns far
+// bigger than memory can be read. Only the B3/S23 rule is accepted.
+type RleReader struct {
+	// Width and Height are the size of the pattern, from its header.
+	Width, Height int
+	r             *bufio.Reader
+	cells         []bool
+	y             int
+	emptyRows     int  // rows already ended by a run of row ends but not yet returned
+	ended         bool // whether the pattern's ! has been read
+}
+
+// NewRleReader reads the header of a pattern, skipping any comment lines before it.
+func NewRleReader(r *bufio.Reader) (*RleReader, error) {
+	var header string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("RLE pattern has no header: %v", err)
+		}
+		if !strings.HasPrefix(line, "#") {
+			header = line
+			break
+		}
+	}
+	p := &RleReader{Width: -1, Height: -1, r: r}
+	for _, field := range strings.Split(header, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed RLE header %q", header)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "x":
+			p.Width, err = strconv.Atoi(value)
+		case "y":
+			p.Height, err = strconv.Atoi(value)
+		case "rule":
+			if rule := strings.ToUpper(value); rule != "B3/S23" && rule != "23/3" {
+				err = fmt.Errorf("unsupported rule %v", value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.Width < 0 || p.Height < 0 {
+		return nil, fmt.Errorf("malformed RLE header %q", header)
+	}
+	p.cells = make([]bool, p.Width)
+	return p, nil
+}
+
+// ReadRow returns the next row of the pattern, which is reused by the following call.
+// It returns io.EOF once all Height rows have been read.
+func (p *RleReader) ReadRow() ([]bool, error) {
+	if p.y == p.Height {
+		return nil, io.EOF
+	}
+	for i := range p.cells {
+		p.cells[i] = false
+	}
+	p.y++
+	if p.emptyRows > 0 {
+		p.emptyRows--
+		return p.cells, nil
+	}
+	if p.ended {
+		return p.cells, nil
+	}
+
+	x, count := 0, 0
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF {
+			return nil, fmt.Errorf("RLE pattern has no end")
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case b >= '0' && b <= '9':
+			count = count*10 + int(b-'0')
+			continue
+		case b == 'b' || b == '.':
+			x += max(count, 1)
+		case b == 'o' || b >= 'A' && b <= 'Z':
+			for n := max(count, 1); n > 0; n-- {
+				if x >= p.Width {
+					return nil, fmt.Errorf("RLE row %v is wider than %v", p.y-1, p.Width)
+				}
+				p.cells[x] = true
+				x++
+			}
+		case b == '$':
+			p.emptyRows = max(count, 1) - 1
+			return p.cells, nil
+		case b == '!':
+			p.ended = true
+			return p.cells, nil
+		case b == ' ' || b == '\t' || b == '\r' || b == '\n':
+		default:
+			return nil, fmt.Errorf("unexpected %q in RLE pattern", b)
+		}
+		count = 0
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,193 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/metrics"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// ErrShuttingDown is returned for jobs submitted after the service has started draining.
+var ErrShuttingDown = errors.New("Server is shutting down")
+
+// Service lets other programs submit Game of Life runs and fetch their worlds over RPC.
+// Every submitted job is run headless in the background and kept until the program exits.
+// A job writes its images to out/jobs/<ID>, so that they do not overwrite the main game's.
+type Service struct {
+	mutex    sync.Mutex
+	jobs     []*job
+	draining bool
+	running  sync.WaitGroup
+}
+
+type job struct {
+	params         Params
+	completedTurns int
+	threads        int
+	done           bool
+	alive          []util.Cell
+	keyPresses     chan rune
+}
+
+type JobRequest struct {
+	ID int
+}
+
+type JobResponse struct {
+	ID int
+}
+
+// ThreadsRequest asks for a running job to use Delta more workers, or fewer if it is negative.
+type ThreadsRequest struct {
+	ID    int
+	Delta int
+}
+
+// WorldResponse describes the progress of a job. Alive is only filled in once Done is set.
+type WorldResponse struct {
+	Params         Params
+	CompletedTurns int
+	Threads        int
+	Done           bool
+	Alive          []util.Cell
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+// Submit starts a new run and returns its job ID straight away.
+func (s *Service) Submit(p Params, res *JobResponse) (err error) {
+	if p.ImageWidth <= 0 || p.ImageHeight <= 0 || p.Threads <= 0 && !p.AutoThreads || p.Turns < 0 {
+		err = errors.New("Invalid params")
+		return
+	}
+	// Jobs must not write files wherever a client asks.
+	if p.OutOfCore != "" {
+		err = errors.New("Out-of-core jobs are not served")
+		return
+	}
+	// The io goroutine panics on a missing image, which would take the whole server down.
+	if _, err = os.Stat(fmt.Sprintf("images/%vx%v.pgm", p.ImageWidth, p.ImageHeight)); err != nil {
+		return
+	}
+
+	j := &job{params: p, threads: p.Threads, keyPresses: make(chan rune, 100)}
+	s.mutex.Lock()
+	if s.draining {
+		s.mutex.Unlock()
+		err = ErrShuttingDown
+		return
+	}
+	s.jobs = append(s.jobs, j)
+	res.ID = len(s.jobs) - 1
+	s.running.Add(1)
+	s.mutex.Unlock()
+
+	events := make(chan Event, 1000)
+	// Each job records its own metrics, which would otherwise overwrite the main game's.
+	go run(p, events, j.keyPresses, runOptions{
+		metrics: metrics.New(),
+		outDir:  filepath.Join("out", "jobs", strconv.Itoa(res.ID)),
+	})
+	go func() {
+		defer s.running.Done()
+		for event := range events {
+			switch e := event.(type) {
+			case TurnComplete:
+				s.mutex.Lock()
+				j.completedTurns = e.CompletedTurns
+				s.mutex.Unlock()
+			case ThreadsChanged:
+				s.mutex.Lock()
+				j.threads = e.Threads
+				s.mutex.Unlock()
+			case FinalTurnComplete:
+				s.mutex.Lock()
+				j.completedTurns = e.CompletedTurns
+				j.alive = e.Alive
+				j.done = true
+				s.mutex.Unlock()
+			}
+		}
+	}()
+	return
+}
+
+// Close refuses any new jobs. Running jobs carry on and their worlds can still be fetched.
+func (s *Service) Close() {
+	s.mutex.Lock()
+	s.draining = true
+	s.mutex.Unlock()
+}
+
+// Drain refuses any new jobs and waits up to timeout for the running ones to finish.
+// Their worlds can still be fetched meanwhile. It returns false if some jobs were still
+// running when the timeout expired.
+func (s *Service) Drain(timeout time.Duration) bool {
+	s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// World reports how far a job has got and its final world once it has finished.
+func (s *Service) World(req JobRequest, res *WorldResponse) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if req.ID < 0 || req.ID >= len(s.jobs) {
+		err = fmt.Errorf("Unknown job %v", req.ID)
+		return
+	}
+	j := s.jobs[req.ID]
+	res.Params = j.params
+	res.CompletedTurns = j.completedTurns
+	res.Threads = j.threads
+	res.Done = j.done
+	res.Alive = j.alive
+	return
+}
+
+// Threads changes how many workers a running job uses. The change takes effect one worker
+// at a time at the job's next turn boundaries, and World reports the job's current count.
+func (s *Service) Threads(req ThreadsRequest, res *JobResponse) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if req.ID < 0 || req.ID >= len(s.jobs) {
+		err = fmt.Errorf("Unknown job %v", req.ID)
+		return
+	}
+	j := s.jobs[req.ID]
+	if j.done {
+		err = fmt.Errorf("Job %v has finished", req.ID)
+		return
+	}
+	key, n := '+', req.Delta
+	if n < 0 {
+		key, n = '-', -n
+	}
+	if n > cap(j.keyPresses)-len(j.keyPresses) {
+		err = fmt.Errorf("Job %v cannot change by %v threads at once", req.ID, req.Delta)
+		return
+	}
+	for i := 0; i < n; i++ {
+		j.keyPresses <- key
+	}
+	res.ID = req.ID
+	return
+}
@@ -0,0 +1,87 @@
+package gol
+
+import (
+	"runtime/trace"
+	"time"
+)
+
+// sharedWorkers run the Shared mode. One worker per tile lives for the whole game.
+// Each turn the distributor publishes the world at the start barrier, every worker
+// writes its tile of the next world into the common next buffer and records its
+// strip, and the distributor collects the strips after the done barrier.
+// The barriers order every access, so the fields need no other locking.
+type sharedWorkers struct {
+	p       Params
+	c       distributorChannels
+	start   *barrier
+	done    *barrier
+	world   [][]byte
+	next    [][]byte
+	strips  []strip
+	stopped bool
+}
+
+func newSharedWorkers(p Params, tiles []tile, c distributorChannels) *sharedWorkers {
+	s := &sharedWorkers{
+		p:      p,
+		c:      c,
+		start:  newBarrier(len(tiles) + 1),
+		done:   newBarrier(len(tiles) + 1),
+		next:   initWorld(p.ImageHeight, p.ImageWidth),
+		strips: make([]strip, len(tiles)),
+	}
+	for i, t := range tiles {
+		go s.worker(i, t)
+	}
+	return s
+}
+
+func (s *sharedWorkers) worker(i int, t tile) {
+	cells := make([][]byte, t.height())
+	for {
+		s.start.wait()
+		if s.stopped {
+			return
+		}
+		start := time.Now()
+		c := s.c
+		for y := range cells {
+			cells[y] = s.next[t.startY+y][t.startX:t.endX]
+		}
+		region := trace.StartRegion(c.ctx, workerRegion)
+		calculateNextStateInto(cells, t.startY, t.endY, t.startX, t.endX, s.p, s.world, c)
+		region.End()
+		if faultInjector != nil {
+			faultInjector(t, cells)
+		}
+		s.strips[i] = strip{
+			tile:     t,
+			worker:   i,
+			cells:    cells,
+			checksum: stripChecksum(s.world, t, cells),
+			alive:    countAlive(cells),
+			duration: time.Since(start),
+		}
+		s.done.wait()
+	}
+}
+
+// step computes the turn after world, returning the next world and the strips it is made of.
+// The strips' cells are views into the next world, which is a buffer owned by the workers,
+// and world becomes the buffer written by the following step, so the caller must stop using
+// world by then.
+func (s *sharedWorkers) step(world [][]byte, completedTurns int) ([][]byte, []strip) {
+	s.world = world
+	s.c.completedTurns = completedTurns
+	s.start.wait()
+	s.done.wait()
+	next := s.next
+	s.next = world
+	return next, s.strips
+}
+
+// stop ends the workers. It must not be called during a step.
+func (s *sharedWorkers) stop() {
+	s.stopped = true
+	s.start.wait()
+}
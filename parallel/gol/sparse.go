@@ -0,0 +1,258 @@
+package gol
+
+import (
+	"runtime/trace"
+	"sort"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// chunkSize is the width and height of the chunks a sparse world is stored in.
+const chunkSize = 32
+
+// chunk is a square of cells in a sparse world, indexed [y][x].
+type chunk [chunkSize][chunkSize]bool
+
+// chunkKey is a chunk's position, in chunks from the one holding cell (0, 0).
+type chunkKey struct {
+	X, Y int
+}
+
+// sparseWorld is an unbounded plane used when Params.Unbounded is set. Only the chunks
+// with alive cells in them are stored, so patterns can travel without wrapping around.
+type sparseWorld struct {
+	chunks map[chunkKey]*chunk
+}
+
+// newSparseWorld places world on the plane with its top left cell at (0, 0).
+func newSparseWorld(world [][]byte) *sparseWorld {
+	s := &sparseWorld{chunks: make(map[chunkKey]*chunk)}
+	for y, row := range world {
+		for x, cell := range row {
+			if cell == 255 {
+				key, cx, cy := locate(x, y)
+				if s.chunks[key] == nil {
+					s.chunks[key] = new(chunk)
+				}
+				s.chunks[key][cy][cx] = true
+			}
+		}
+	}
+	return s
+}
+
+// locate returns the chunk holding cell (x, y) and the cell's position within it.
+func locate(x, y int) (key chunkKey, cx, cy int) {
+	key = chunkKey{floorDiv(x, chunkSize), floorDiv(y, chunkSize)}
+	return key, x - key.X*chunkSize, y - key.Y*chunkSize
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// aliveCells returns the alive cells in row-major order.
+func (s *sparseWorld) aliveCells() []util.Cell {
+	var cells []util.Cell
+	for key, c := range s.chunks {
+		for cy := range c {
+			for cx, alive := range c[cy] {
+				if alive {
+					cells = append(cells, util.Cell{X: key.X*chunkSize + cx, Y: key.Y*chunkSize + cy})
+				}
+			}
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+func (s *sparseWorld) count() int {
+	n := 0
+	for _, c := range s.chunks {
+		n += c.count()
+	}
+	return n
+}
+
+func (c *chunk) count() int {
+	n := 0
+	for cy := range c {
+		for _, alive := range c[cy] {
+			if alive {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// bounds returns the smallest rectangle holding every alive cell, or the single cell at
+// (0, 0) if there are none, so that a snapshot always has a size.
+func (s *sparseWorld) bounds() tile {
+	box := tile{}
+	found := false
+	for key, c := range s.chunks {
+		for cy := range c {
+			for cx, alive := range c[cy] {
+				if !alive {
+					continue
+				}
+				x, y := key.X*chunkSize+cx, key.Y*chunkSize+cy
+				if !found {
+					box = tile{startX: x, endX: x + 1, startY: y, endY: y + 1}
+					found = true
+					continue
+				}
+				if x < box.startX {
+					box.startX = x
+				}
+				if x >= box.endX {
+					box.endX = x + 1
+				}
+				if y < box.startY {
+					box.startY = y
+				}
+				if y >= box.endY {
+					box.endY = y + 1
+				}
+			}
+		}
+	}
+	if !found {
+		return tile{startX: 0, endX: 1, startY: 0, endY: 1}
+	}
+	return box
+}
+
+// dense copies the cells in box into a world of box's size.
+func (s *sparseWorld) dense(box tile) [][]byte {
+	world := initWorld(box.height(), box.width())
+	for key, c := range s.chunks {
+		for cy := range c {
+			for cx, alive := range c[cy] {
+				x, y := key.X*chunkSize+cx, key.Y*chunkSize+cy
+				if alive && x >= box.startX && x < box.endX && y >= box.startY && y < box.endY {
+					world[y-box.startY][x-box.startX] = 255
+				}
+			}
+		}
+	}
+	return world
+}
+
+// step computes the turn after s, splitting the chunks that may change between p.Threads
+// workers. A chunk may change if it or one of its neighbours has alive cells in it.
+func (s *sparseWorld) step(p Params, c distributorChannels) (*sparseWorld, []time.Duration, int) {
+	active := make(map[chunkKey]bool, 2*len(s.chunks))
+	for key := range s.chunks {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				active[chunkKey{key.X + dx, key.Y + dy}] = true
+			}
+		}
+	}
+	keys := make([]chunkKey, 0, len(active))
+	for key := range active {
+		keys = append(keys, key)
+	}
+
+	type result struct {
+		chunks   map[chunkKey]*chunk
+		alive    int
+		duration time.Duration
+	}
+	results := make(chan result, p.Threads)
+	for w := 0; w < p.Threads; w++ {
+		go func(w int) {
+			start := time.Now()
+			region := trace.StartRegion(c.ctx, workerRegion)
+			r := result{chunks: make(map[chunkKey]*chunk)}
+			for i := w; i < len(keys); i += p.Threads {
+				next, alive := s.stepChunk(keys[i], c)
+				if alive > 0 {
+					r.chunks[keys[i]] = next
+					r.alive += alive
+				}
+			}
+			region.End()
+			r.duration = time.Since(start)
+			results <- r
+		}(w)
+	}
+
+	next := &sparseWorld{chunks: make(map[chunkKey]*chunk, len(s.chunks))}
+	workerTimes := make([]time.Duration, p.Threads)
+	alive := 0
+	for w := range workerTimes {
+		r := <-results
+		for key, ch := range r.chunks {
+			next.chunks[key] = ch
+		}
+		workerTimes[w] = r.duration
+		alive += r.alive
+	}
+	return next, workerTimes, alive
+}
+
+// stepChunk computes the next state of the chunk at key, sending CellFlipped events if
+// c.events is not nil, and returns it with how many of its cells are alive.
+func (s *sparseWorld) stepChunk(key chunkKey, c distributorChannels) (*chunk, int) {
+	// The chunk with a border of one cell taken from its neighbours.
+	var padded [chunkSize + 2][chunkSize + 2]bool
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			neighbour := s.chunks[chunkKey{key.X + dx, key.Y + dy}]
+			if neighbour == nil {
+				continue
+			}
+			for cy := 0; cy < chunkSize; cy++ {
+				py := cy + 1 + dy*chunkSize
+				if py < 0 || py >= chunkSize+2 {
+					continue
+				}
+				for cx := 0; cx < chunkSize; cx++ {
+					px := cx + 1 + dx*chunkSize
+					if px >= 0 && px < chunkSize+2 {
+						padded[py][px] = neighbour[cy][cx]
+					}
+				}
+			}
+		}
+	}
+
+	next := new(chunk)
+	alive := 0
+	for cy := 0; cy < chunkSize; cy++ {
+		for cx := 0; cx < chunkSize; cx++ {
+			neighbours := 0
+			for py := cy; py <= cy+2; py++ {
+				for px := cx; px <= cx+2; px++ {
+					if padded[py][px] && (py != cy+1 || px != cx+1) {
+						neighbours++
+					}
+				}
+			}
+			was := padded[cy+1][cx+1]
+			now := neighbours == 3 || neighbours == 2 && was
+			next[cy][cx] = now
+			if now {
+				alive++
+			}
+			if now != was && c.events != nil {
+				c.events <- CellFlipped{CompletedTurns: c.completedTurns, Cell: util.Cell{X: key.X*chunkSize + cx, Y: key.Y*chunkSize + cy}}
+			}
+		}
+	}
+	return next, alive
+}
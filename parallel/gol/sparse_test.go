@@ -0,0 +1,131 @@
+package gol
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSparseMatchesDense runs a random soup in the middle of a world too big for it to
+// reach the edges, so that wrapping makes no difference, and checks the sparse plane
+// against the dense engine every turn.
+func TestSparseMatchesDense(t *testing.T) {
+	const size, soup, turns = 160, 24, 40
+	world := initWorld(size, size)
+	random := rand.New(rand.NewSource(1))
+	for y := (size - soup) / 2; y < (size+soup)/2; y++ {
+		for x := (size - soup) / 2; x < (size+soup)/2; x++ {
+			if random.Intn(3) == 0 {
+				world[y][x] = 255
+			}
+		}
+	}
+	for _, threads := range []int{1, 3, 8} {
+		t.Run(fmt.Sprint(threads), func(t *testing.T) {
+			p := Params{ImageWidth: size, ImageHeight: size, Threads: threads}
+			plane := newSparseWorld(world)
+			dense := world
+			for turn := 1; turn <= turns; turn++ {
+				next := initWorld(size, size)
+				calculateNextStateInto(next, 0, size, 0, size, p, dense, distributorChannels{})
+				dense = next
+				var alive int
+				plane, _, alive = plane.step(p, distributorChannels{})
+
+				expected := calculateAliveCells(p, dense)
+				cells := plane.aliveCells()
+				if fmt.Sprint(cells) != fmt.Sprint(expected) {
+					t.Fatalf("ERROR: Turn %v has alive cells %v, expected %v", turn, cells, expected)
+				}
+				if alive != len(expected) || plane.count() != len(expected) {
+					t.Fatalf("ERROR: Turn %v counted %v and %v alive cells, expected %v", turn, alive, plane.count(), len(expected))
+				}
+			}
+		})
+	}
+}
+
+// TestSparseGlider sends gliders across chunk boundaries in each direction, including into
+// negative coordinates, and checks where they are and the events they send.
+func TestSparseGlider(t *testing.T) {
+	// A glider heading down and right, as it is in its first phase.
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	for _, direction := range [][2]int{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}} {
+		t.Run(fmt.Sprint(direction), func(t *testing.T) {
+			const turns = 4 * 2 * chunkSize
+			world := initWorld(3, 3)
+			for _, cell := range glider {
+				x, y := cell.X, cell.Y
+				if direction[0] < 0 {
+					x = 2 - x
+				}
+				if direction[1] < 0 {
+					y = 2 - y
+				}
+				world[y][x] = 255
+			}
+			p := Params{ImageWidth: 3, ImageHeight: 3, Threads: 2}
+			plane := newSparseWorld(world)
+			flips := make(map[util.Cell]bool)
+			for _, cell := range plane.aliveCells() {
+				flips[cell] = true
+			}
+			for turn := 1; turn <= turns; turn++ {
+				events := make(chan Event, 64)
+				plane, _, _ = plane.step(p, distributorChannels{events: events, completedTurns: turn})
+				close(events)
+				for e := range events {
+					cell := e.(CellFlipped).Cell
+					flips[cell] = !flips[cell]
+				}
+			}
+
+			// Every four turns a glider is back in its first phase, one cell further on.
+			shift := turns / 4
+			box := plane.bounds()
+			expected := tile{startX: direction[0] * shift, endX: direction[0]*shift + 3, startY: direction[1] * shift, endY: direction[1]*shift + 3}
+			if box != expected {
+				t.Fatalf("ERROR: Glider is at %+v, expected %+v", box, expected)
+			}
+			dense := plane.dense(box)
+			for y := range world {
+				if string(dense[y]) != string(world[y]) {
+					t.Fatalf("ERROR: Glider is %v, expected %v", dense, world)
+				}
+			}
+			if len(plane.chunks) > 4 {
+				t.Errorf("ERROR: Plane keeps %v chunks, expected at most 4", len(plane.chunks))
+			}
+
+			var flipped []util.Cell
+			for cell, alive := range flips {
+				if alive {
+					flipped = append(flipped, cell)
+				}
+			}
+			if len(flipped) != len(glider) {
+				t.Errorf("ERROR: CellFlipped events leave %v alive, expected %v", flipped, plane.aliveCells())
+			}
+			for _, cell := range plane.aliveCells() {
+				if !flips[cell] {
+					t.Errorf("ERROR: CellFlipped events leave %v dead", cell)
+				}
+			}
+		})
+	}
+}
+
+// TestSparseEmpty checks that a plane with nothing alive still has a one cell snapshot.
+func TestSparseEmpty(t *testing.T) {
+	plane := newSparseWorld(initWorld(4, 4))
+	plane, _, alive := plane.step(Params{Threads: 2}, distributorChannels{})
+	if alive != 0 || len(plane.aliveCells()) != 0 {
+		t.Errorf("ERROR: Empty plane has %v alive cells", alive)
+	}
+	box := plane.bounds()
+	if box.width() != 1 || box.height() != 1 || plane.dense(box)[0][0] != 0 {
+		t.Errorf("ERROR: Empty plane has bounds %+v", box)
+	}
+}
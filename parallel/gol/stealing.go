@@ -0,0 +1,205 @@
+package gol
+
+import (
+	"runtime/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tilesPerWorker is how many tiles the Stealing mode cuts the world into for each thread.
+// More tiles allow finer balancing but cost more scheduling and halo reads.
+const tilesPerWorker = 8
+
+// flipCost is the estimated cost of a flipped cell, in units of the cost of updating one cell.
+// A flip sends a CellFlipped event, which is far slower than counting neighbours.
+const flipCost = 32
+
+// stealingWorkers run the Stealing mode. Like the Shared mode, one worker per thread lives
+// for the whole game and writes into a common next buffer between two barriers, but the
+// world is cut into many small tiles. Each turn the tiles are dealt out to the workers'
+// queues by their cost in the previous turn, most expensive first, and a worker that runs
+// out of tiles steals from the back of another worker's queue.
+type stealingWorkers struct {
+	p       Params
+	c       distributorChannels
+	start   *barrier
+	done    *barrier
+	world   [][]byte
+	next    [][]byte
+	tiles   []tile
+	costs   []int // estimated cost of each tile, from the previous turn's flips
+	order   tileOrder
+	loads   []int
+	queues  []tileQueue
+	cells   [][][]byte // the rows of each tile in next, kept between turns
+	strips  []strip
+	stopped bool
+}
+
+// tileOrder sorts tile indices by descending cost.
+type tileOrder struct {
+	tiles []int
+	costs []int
+}
+
+func (o *tileOrder) Len() int           { return len(o.tiles) }
+func (o *tileOrder) Less(a, b int) bool { return o.costs[o.tiles[a]] > o.costs[o.tiles[b]] }
+func (o *tileOrder) Swap(a, b int)      { o.tiles[a], o.tiles[b] = o.tiles[b], o.tiles[a] }
+
+// tileQueue is a worker's queue of tile indices. The owner takes from the front and
+// thieves take from the back, so they only contend over the last few tiles.
+type tileQueue struct {
+	mutex sync.Mutex
+	tiles []int
+	head  int // the front of the queue, so that tiles keeps its capacity for the next turn
+}
+
+func (q *tileQueue) popFront() (int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.head == len(q.tiles) {
+		return 0, false
+	}
+	i := q.tiles[q.head]
+	q.head++
+	return i, true
+}
+
+func (q *tileQueue) popBack() (int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.head == len(q.tiles) {
+		return 0, false
+	}
+	i := q.tiles[len(q.tiles)-1]
+	q.tiles = q.tiles[:len(q.tiles)-1]
+	return i, true
+}
+
+func newStealingWorkers(p Params, c distributorChannels) *stealingWorkers {
+	tiles := planTiles(p.ImageWidth, p.ImageHeight, p.Threads*tilesPerWorker)
+	workers := p.Threads
+	if workers > len(tiles) {
+		workers = len(tiles)
+	}
+	s := &stealingWorkers{
+		p:      p,
+		c:      c,
+		start:  newBarrier(workers + 1),
+		done:   newBarrier(workers + 1),
+		next:   initWorld(p.ImageHeight, p.ImageWidth),
+		tiles:  tiles,
+		costs:  make([]int, len(tiles)),
+		loads:  make([]int, workers),
+		queues: make([]tileQueue, workers),
+		cells:  make([][][]byte, len(tiles)),
+		strips: make([]strip, len(tiles)),
+	}
+	s.order = tileOrder{tiles: make([]int, len(tiles)), costs: s.costs}
+	for i, t := range tiles {
+		s.costs[i] = t.width() * t.height()
+		s.order.tiles[i] = i
+		s.cells[i] = make([][]byte, t.height())
+	}
+	for q := range s.queues {
+		s.queues[q].tiles = make([]int, 0, len(tiles))
+	}
+	for i := 0; i < workers; i++ {
+		go s.worker(i)
+	}
+	return s
+}
+
+// deal fills the queues for the next turn. The most expensive tiles are dealt first,
+// each to the queue with the least work so far.
+func (s *stealingWorkers) deal() {
+	// Starting from the previous turn's order, which is nearly sorted already.
+	sort.Stable(&s.order)
+
+	for q := range s.queues {
+		s.queues[q].tiles = s.queues[q].tiles[:0]
+		s.queues[q].head = 0
+		s.loads[q] = 0
+	}
+	for _, i := range s.order.tiles {
+		least := 0
+		for q := range s.loads {
+			if s.loads[q] < s.loads[least] {
+				least = q
+			}
+		}
+		s.queues[least].tiles = append(s.queues[least].tiles, i)
+		s.loads[least] += s.costs[i]
+	}
+}
+
+// take returns the next tile for worker w, stealing once its own queue is empty.
+func (s *stealingWorkers) take(w int) (int, bool) {
+	if i, ok := s.queues[w].popFront(); ok {
+		return i, true
+	}
+	for n := 1; n < len(s.queues); n++ {
+		if i, ok := s.queues[(w+n)%len(s.queues)].popBack(); ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *stealingWorkers) worker(w int) {
+	for {
+		s.start.wait()
+		if s.stopped {
+			return
+		}
+		c := s.c
+		for {
+			i, ok := s.take(w)
+			if !ok {
+				break
+			}
+			start := time.Now()
+			t := s.tiles[i]
+			cells := s.cells[i]
+			for y := range cells {
+				cells[y] = s.next[t.startY+y][t.startX:t.endX]
+			}
+			region := trace.StartRegion(c.ctx, workerRegion)
+			flips := calculateNextStateInto(cells, t.startY, t.endY, t.startX, t.endX, s.p, s.world, c)
+			region.End()
+			if faultInjector != nil {
+				faultInjector(t, cells)
+			}
+			s.costs[i] = t.width()*t.height() + flipCost*flips
+			s.strips[i] = strip{
+				tile:     t,
+				worker:   w,
+				cells:    cells,
+				checksum: stripChecksum(s.world, t, cells),
+				alive:    countAlive(cells),
+				duration: time.Since(start),
+			}
+		}
+		s.done.wait()
+	}
+}
+
+// step computes the turn after world, returning the next world and the strips it is made of,
+// with the same ownership rules as sharedWorkers.step.
+func (s *stealingWorkers) step(world [][]byte, completedTurns int) ([][]byte, []strip) {
+	s.world = world
+	s.c.completedTurns = completedTurns
+	s.deal()
+	s.start.wait()
+	s.done.wait()
+	next := s.next
+	s.next = world
+	return next, s.strips
+}
+
+// stop ends the workers. It must not be called during a step.
+func (s *stealingWorkers) stop() {
+	s.stopped = true
+	s.start.wait()
+}
@@ -0,0 +1,146 @@
+package gol
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/metrics"
+)
+
+// TestStealingDeal checks that dealing skewed costs leaves every queue within one tile of the others.
+func TestStealingDeal(t *testing.T) {
+	p := Params{Threads: 4, ImageWidth: 64, ImageHeight: 64}
+	s := newStealingWorkers(p, distributorChannels{ctx: context.Background()})
+	defer s.stop()
+	// All the activity is in the first few tiles, as with a glider gun in one corner.
+	largest := 0
+	for i := range s.costs {
+		if i < 3 {
+			s.costs[i] += 1000 * flipCost
+		}
+		if s.costs[i] > largest {
+			largest = s.costs[i]
+		}
+	}
+	s.deal()
+
+	seen := make([]bool, len(s.tiles))
+	lo, hi := -1, 0
+	for q := range s.queues {
+		load := 0
+		for _, i := range s.queues[q].tiles {
+			if seen[i] {
+				t.Fatalf("ERROR: Tile %v was dealt twice", i)
+			}
+			seen[i] = true
+			load += s.costs[i]
+		}
+		if lo < 0 || load < lo {
+			lo = load
+		}
+		if load > hi {
+			hi = load
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Fatalf("ERROR: Tile %v was not dealt", i)
+		}
+	}
+	if hi-lo > largest {
+		t.Errorf("ERROR: Queue loads range from %v to %v, more than the largest tile %v apart", lo, hi, largest)
+	}
+}
+
+// TestStealingTake checks that a worker with an empty queue steals from the back of another's.
+func TestStealingTake(t *testing.T) {
+	s := &stealingWorkers{queues: make([]tileQueue, 3)}
+	s.queues[1].tiles = []int{4, 5, 6}
+	if i, ok := s.take(1); !ok || i != 4 {
+		t.Errorf("ERROR: Owner took %v, %v, expected the front tile 4", i, ok)
+	}
+	if i, ok := s.take(0); !ok || i != 6 {
+		t.Errorf("ERROR: Thief took %v, %v, expected the back tile 6", i, ok)
+	}
+	if i, ok := s.take(2); !ok || i != 5 {
+		t.Errorf("ERROR: Thief took %v, %v, expected the last tile 5", i, ok)
+	}
+	if _, ok := s.take(0); ok {
+		t.Error("ERROR: Took a tile from empty queues")
+	}
+}
+
+// BenchmarkSkewed runs a 512x512 world whose only activity is a random soup in one corner.
+// The Channels and Shared modes give that corner to a few workers, while the Stealing mode
+// spreads it over every worker, so compare the modes on a machine with several CPUs, e.g.
+//
+//	go test -run ^$ -bench ^BenchmarkSkewed$ ./gol
+func BenchmarkSkewed(b *testing.B) {
+	const size = 512
+	world := initWorld(size, size)
+	random := rand.New(rand.NewSource(1))
+	for y := 0; y < size/4; y++ {
+		for x := 0; x < size/4; x++ {
+			if random.Intn(2) == 0 {
+				world[y][x] = 255
+			}
+		}
+	}
+
+	for _, mode := range []Mode{Channels, Shared, Stealing} {
+		p := Params{Turns: 100, Threads: 8, ImageWidth: size, ImageHeight: size, Mode: mode}
+		b.Run(fmt.Sprintf("mode=%v/threads=%d", p.Mode, p.Threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				runWithWorld(p, world)
+			}
+		})
+	}
+}
+
+// runWithWorld runs the distributor from world, standing in for the io goroutine
+// and draining the events.
+func runWithWorld(p Params, world [][]byte) {
+	events := make(chan Event, 1000)
+	ioCommands := make(chan ioCommand)
+	ioIdle := make(chan bool)
+	ioFilename := make(chan string)
+	output := make(chan uint8)
+	input := make(chan uint8)
+	c := distributorChannels{
+		events:     events,
+		ioCommand:  ioCommands,
+		ioIdle:     ioIdle,
+		ioFilename: ioFilename,
+		ioOutput:   output,
+		ioInput:    input,
+		keyPresses: make(chan rune),
+		ctx:        context.Background(),
+		metrics:    metrics.New(),
+	}
+	go func() {
+		for command := range ioCommands {
+			switch command {
+			case ioInput:
+				<-ioFilename
+				for _, row := range world {
+					for _, cell := range row {
+						input <- cell
+					}
+				}
+			case ioOutput:
+				<-ioFilename
+				for n := 0; n < p.ImageWidth*p.ImageHeight; n++ {
+					<-output
+				}
+			case ioCheckIdle:
+				ioIdle <- true
+			}
+		}
+	}()
+	go distributor(p, c)
+	for range events {
+	}
+	close(ioCommands)
+}
@@ -0,0 +1,83 @@
+package gol
+
+// tile is the rectangle of cells [startX, endX) x [startY, endY) given to one worker.
+type tile struct {
+	startX, endX int
+	startY, endY int
+}
+
+func (t tile) width() int  { return t.endX - t.startX }
+func (t tile) height() int { return t.endY - t.startY }
+
+// planTiles splits a width x height world into balanced tiles, one per thread.
+// The tiles are laid out in bands of rows, where each band holds the same number
+// of tiles give or take one and is as tall as its share of the tiles. Every band
+// count is tried and the plan with the smallest largest tile wins, preferring
+// the one with the shortest tile edges, as those are the halo cells a worker
+// reads from its neighbours. There are never more tiles than cells, and every
+// tile has at least one cell.
+func planTiles(width, height, threads int) []tile {
+	n := threads
+	if n > width*height {
+		n = width * height
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	var best []tile
+	bestArea, bestEdges := 0, 0
+	for bands := 1; bands <= height && bands <= n; bands++ {
+		tiles := planBands(width, height, n, bands)
+		if tiles == nil {
+			continue
+		}
+		area, edges := 0, 0
+		for _, t := range tiles {
+			if a := t.width() * t.height(); a > area {
+				area = a
+			}
+			edges += t.width() + t.height()
+		}
+		if best == nil || area < bestArea || area == bestArea && edges < bestEdges {
+			best, bestArea, bestEdges = tiles, area, edges
+		}
+	}
+	return best
+}
+
+// planBands lays out n tiles in the given number of bands, or returns nil if a band
+// would need more tiles than there are columns.
+func planBands(width, height, n, bands int) []tile {
+	if (n+bands-1)/bands > width {
+		return nil
+	}
+	tiles := make([]tile, 0, n)
+	startY := 0
+	assigned := 0
+	for band := 0; band < bands; band++ {
+		count := n / bands
+		if band < n%bands {
+			count++
+		}
+		assigned += count
+		// Band heights follow their share of the tiles, keeping at least one row for each later band.
+		endY := (height*assigned + n/2) / n
+		if endY <= startY {
+			endY = startY + 1
+		}
+		if maxEndY := height - (bands - band - 1); endY > maxEndY {
+			endY = maxEndY
+		}
+		for i := 0; i < count; i++ {
+			tiles = append(tiles, tile{
+				startX: width * i / count,
+				endX:   width * (i + 1) / count,
+				startY: startY,
+				endY:   endY,
+			})
+		}
+		startY = endY
+	}
+	return tiles
+}
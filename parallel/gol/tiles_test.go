@@ -0,0 +1,62 @@
+package gol
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestPlanTiles checks every thread count from 1 to 64 against square, non-square and
+// tiny worlds: the tiles must cover every cell exactly once, there must be one per thread
+// unless there are fewer cells than threads, and no tile may be half as big again as its fair share.
+func TestPlanTiles(t *testing.T) {
+	sizes := [][2]int{
+		{1, 1}, {2, 3}, {5, 1}, {1, 7}, {8, 8}, {16, 16}, {16, 4}, {3, 100},
+		{64, 64}, {100, 7}, {512, 512}, {512, 64}, {37, 91},
+	}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		for threads := 1; threads <= 64; threads++ {
+			t.Run(fmt.Sprintf("%dx%d-%d", width, height, threads), func(t *testing.T) {
+				tiles := planTiles(width, height, threads)
+
+				want := threads
+				if want > width*height {
+					want = width * height
+				}
+				if len(tiles) != want {
+					t.Fatalf("ERROR: Expected %v tiles, got %v", want, len(tiles))
+				}
+
+				covered := make([]int, width*height)
+				largest := 0
+				for _, tile := range tiles {
+					if tile.width() <= 0 || tile.height() <= 0 {
+						t.Fatalf("ERROR: Empty tile %+v", tile)
+					}
+					if tile.startX < 0 || tile.endX > width || tile.startY < 0 || tile.endY > height {
+						t.Fatalf("ERROR: Tile %+v is outside the world", tile)
+					}
+					for y := tile.startY; y < tile.endY; y++ {
+						for x := tile.startX; x < tile.endX; x++ {
+							covered[y*width+x]++
+						}
+					}
+					if area := tile.width() * tile.height(); area > largest {
+						largest = area
+					}
+				}
+				for i, n := range covered {
+					if n != 1 {
+						t.Fatalf("ERROR: Cell (%v, %v) is covered by %v tiles", i%width, i/width, n)
+					}
+				}
+
+				// Tiles are whole cells, so the fairest possible largest tile is the share rounded up.
+				fair := (width*height + len(tiles) - 1) / len(tiles)
+				if largest*2 > fair*3 {
+					t.Errorf("ERROR: Largest tile has %v cells, more than half as many again as the fair %v", largest, fair)
+				}
+			})
+		}
+	}
+}
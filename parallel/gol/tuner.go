@@ -0,0 +1,111 @@
+package gol
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// tuneSample is how much turn time the tuner spends on each thread count it tries.
+	tuneSample = 100 * time.Millisecond
+	// tuneMinTurns and tuneMaxTurns bound how many turns a sample takes, so that
+	// fast turns are not judged on noise and slow turns do not drag sampling out.
+	tuneMinTurns = 3
+	tuneMaxTurns = 50
+	// tuneInterval is how long the tuner keeps a thread count before sampling again.
+	tuneInterval = 10 * time.Second
+	// tuneDrift is how far the settled count's latency may move from when it won before
+	// the world's activity is taken to have changed and the counts are sampled again.
+	tuneDrift = 1.5
+)
+
+// tuner picks the thread count for Params.AutoThreads. It tries each candidate count in
+// turn, keeping it for a sample of turns, then settles on the count whose turns were
+// fastest. It samples again every tuneInterval, or sooner if the settled count slows
+// down or speeds up by tuneDrift as the world's activity changes.
+type tuner struct {
+	candidates []int
+	sampling   int // index of the candidate being sampled, or -1 once settled
+	warm       bool
+	turns      int
+	total      time.Duration
+	means      []time.Duration
+	best       int
+	settledAt  time.Time
+	baseline   time.Duration
+	recent     time.Duration // moving average of the settled count's latency
+}
+
+// tuneCandidates are the thread counts tried: powers of two up to twice the CPUs and the
+// number of CPUs itself, at most one per cell of the world.
+func tuneCandidates(cpus, cells int) []int {
+	var candidates []int
+	for n := 1; n <= 2*cpus && n <= cells; n *= 2 {
+		candidates = append(candidates, n)
+	}
+	// A power of two is already a candidate.
+	if cpus <= cells && cpus&(cpus-1) != 0 {
+		candidates = append(candidates, cpus)
+		sort.Ints(candidates)
+	}
+	return candidates
+}
+
+func newTuner(candidates []int) *tuner {
+	t := &tuner{candidates: candidates}
+	t.means = make([]time.Duration, len(t.candidates))
+	t.best = t.candidates[0]
+	return t
+}
+
+// threads returns the thread count to use for the next turn.
+func (t *tuner) threads() int {
+	if t.sampling < 0 {
+		return t.best
+	}
+	return t.candidates[t.sampling]
+}
+
+// observe records how long a turn took with the count threads returned.
+func (t *tuner) observe(now time.Time, latency time.Duration) {
+	if t.sampling < 0 {
+		t.recent = (3*t.recent + latency) / 4
+		drift := float64(t.recent) / float64(t.baseline)
+		if now.Sub(t.settledAt) >= tuneInterval || drift > tuneDrift || drift < 1/tuneDrift {
+			t.sampling = 0
+			t.warm = false
+		}
+		return
+	}
+
+	// The first turn on a new count pays for starting its workers, so it is not counted.
+	if !t.warm {
+		t.warm = true
+		t.turns, t.total = 0, 0
+		return
+	}
+	t.turns++
+	t.total += latency
+	if t.turns < tuneMaxTurns && (t.turns < tuneMinTurns || t.total < tuneSample) {
+		return
+	}
+
+	t.means[t.sampling] = t.total / time.Duration(t.turns)
+	t.sampling++
+	t.warm = false
+	if t.sampling < len(t.candidates) {
+		return
+	}
+	fastest := 0
+	for i, mean := range t.means {
+		if mean < t.means[fastest] {
+			fastest = i
+		}
+	}
+	t.sampling = -1
+	t.best = t.candidates[fastest]
+	// At least a nanosecond, so that drift can be measured against it.
+	t.baseline = t.means[fastest] + 1
+	t.recent = t.baseline
+	t.settledAt = now
+}
@@ -0,0 +1,73 @@
+package gol
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTuneCandidates(t *testing.T) {
+	tests := []struct {
+		cpus, cells int
+		expected    []int
+	}{
+		{1, 1000, []int{1, 2}},
+		{4, 1000, []int{1, 2, 4, 8}},
+		{6, 1000, []int{1, 2, 4, 6, 8}},
+		{12, 1000, []int{1, 2, 4, 8, 12, 16}},
+		{6, 5, []int{1, 2, 4}},
+		{8, 1, []int{1}},
+	}
+	for _, test := range tests {
+		candidates := tuneCandidates(test.cpus, test.cells)
+		if fmt.Sprint(candidates) != fmt.Sprint(test.expected) {
+			t.Errorf("ERROR: %v CPUs and %v cells gave candidates %v, expected %v", test.cpus, test.cells, candidates, test.expected)
+		}
+	}
+}
+
+// TestTuner runs the tuner against a model of turn latency where workers help up to a point
+// and then cost more than they save, and checks that it settles on the fastest count and
+// moves when the world's activity changes.
+func TestTuner(t *testing.T) {
+	tune := newTuner([]int{1, 2, 4, 8, 16})
+	now := time.Now()
+	work, overhead := 80*time.Millisecond, time.Millisecond
+	run := func(turns int) {
+		for i := 0; i < turns; i++ {
+			n := tune.threads()
+			latency := work/time.Duration(n) + overhead*time.Duration(n)
+			now = now.Add(latency)
+			tune.observe(now, latency)
+		}
+	}
+
+	// The fastest count for 80ms of work at 1ms a worker is 8: 10ms + 8ms.
+	run(200)
+	if tune.sampling >= 0 || tune.threads() != 8 {
+		t.Fatalf("ERROR: Tuner is on %v threads with sampling at %v, expected to settle on 8", tune.threads(), tune.sampling)
+	}
+
+	// Activity dropping a long way makes 2 the fastest: 2.5ms + 2ms, so the tuner must sample again.
+	work = 5 * time.Millisecond
+	run(50)
+	if tune.sampling < 0 && tune.threads() == 8 {
+		t.Fatal("ERROR: Tuner did not notice the world's activity changing")
+	}
+	run(500)
+	if tune.sampling >= 0 || tune.threads() != 2 {
+		t.Fatalf("ERROR: Tuner is on %v threads with sampling at %v, expected to settle on 2", tune.threads(), tune.sampling)
+	}
+
+	// Without any change it still samples again after tuneInterval.
+	settledAt := tune.settledAt
+	for tune.sampling < 0 {
+		run(1)
+		if now.Sub(settledAt) > 2*tuneInterval {
+			t.Fatal("ERROR: Tuner did not sample again after tuneInterval")
+		}
+	}
+	if now.Sub(settledAt) < tuneInterval {
+		t.Errorf("ERROR: Tuner sampled again after %v, before tuneInterval", now.Sub(settledAt))
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads.
+// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads in every mode.
 func TestGol(t *testing.T) {
 	tests := []gol.Params{
 		{ImageWidth: 16, ImageHeight: 16},
@@ -24,9 +24,47 @@ func TestGol(t *testing.T) {
 				p.ImageWidth,
 				p.ImageHeight,
 			)
-			for threads := 1; threads <= 16; threads++ {
+			for _, mode := range []gol.Mode{gol.Channels, gol.Shared, gol.Stealing} {
+				p.Mode = mode
+				for threads := 1; threads <= 16; threads++ {
+					p.Threads = threads
+					testName := fmt.Sprintf("%dx%dx%d-%d-%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, p.Mode)
+					t.Run(testName, func(t *testing.T) {
+						events := make(chan gol.Event)
+						go gol.Run(p, events, nil)
+						var cells []util.Cell
+						for event := range events {
+							switch e := event.(type) {
+							case gol.FinalTurnComplete:
+								cells = e.Alive
+							}
+						}
+						assertEqualBoard(t, cells, expectedAlive, p)
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestGolManyThreads tests 16x16 and 64x64 images on 100 turns using 17-64 worker threads in every mode,
+// including more threads than the 16x16 image has rows.
+func TestGolManyThreads(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16, Turns: 100},
+		{ImageWidth: 64, ImageHeight: 64, Turns: 100},
+	}
+	for _, p := range tests {
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, mode := range []gol.Mode{gol.Channels, gol.Shared, gol.Stealing} {
+			p.Mode = mode
+			for threads := 17; threads <= 64; threads++ {
 				p.Threads = threads
-				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				testName := fmt.Sprintf("%dx%dx%d-%d-%v", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads, p.Mode)
 				t.Run(testName, func(t *testing.T) {
 					events := make(chan gol.Event)
 					go gol.Run(p, events, nil)
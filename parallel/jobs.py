@@ -0,0 +1,51 @@
+""" Submits a Game of Life job over JSON-RPC and fetches the final world.
+
+Start the program with a JSON-RPC port, e.g.
+    go run . -headless -jsonrpc 8090
+and then run
+    python3 jobs.py --server 127.0.0.1:8090 --size 512 --turns 100
+"""
+
+import argparse
+import json
+import socket
+import time
+
+
+def call(sock, reader, method, params, request_id):
+    # Go's net/rpc/jsonrpc codec expects params to be a list holding exactly one object.
+    request = {'method': method, 'params': [params], 'id': request_id}
+    sock.sendall(json.dumps(request).encode() + b'\n')
+    response = json.loads(reader.readline())
+    if response['error'] is not None:
+        raise RuntimeError(response['error'])
+    return response['result']
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument('--server', default='127.0.0.1:8090')
+    parser.add_argument('--size', type=int, default=512, help='Width and height of the image in images/')
+    parser.add_argument('--turns', type=int, default=100)
+    parser.add_argument('--threads', type=int, default=8)
+    args = parser.parse_args()
+
+    host, port = args.server.rsplit(':', 1)
+    sock = socket.create_connection((host, int(port)))
+    reader = sock.makefile('r')
+
+    params = {'Turns': args.turns, 'Threads': args.threads, 'ImageWidth': args.size, 'ImageHeight': args.size}
+    job = call(sock, reader, 'Gol.Submit', params, 0)
+    world = {'Done': False}
+    while not world['Done']:
+        time.sleep(0.5)
+        world = call(sock, reader, 'Gol.World', {'ID': job['ID']}, 1)
+        print('Completed Turns %-8d' % world['CompletedTurns'])
+
+    alive = world['Alive'] or []
+    print('Final Turn Complete, %d alive cells' % len(alive))
+    sock.close()
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestJSONRPC submits a job over JSON-RPC and checks the world it returns.
+func TestJSONRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveJSONRPC(listener, gol.NewService())
+
+	client, err := jsonrpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 16, ImageHeight: 16}
+	job := new(gol.JobResponse)
+	if err := client.Call("Gol.Submit", p, job); err != nil {
+		t.Fatal(err)
+	}
+
+	world := new(gol.WorldResponse)
+	deadline := time.Now().Add(5 * time.Second)
+	for !world.Done {
+		if time.Now().After(deadline) {
+			t.Fatal("ERROR: Job did not finish in 5 seconds")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if err := client.Call("Gol.World", gol.JobRequest{ID: job.ID}, world); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert(t, world.CompletedTurns == p.Turns, "Expected %v completed turns, got %v", p.Turns, world.CompletedTurns)
+	expected := readAliveCells(fmt.Sprintf("check/images/%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns), p.ImageWidth, p.ImageHeight)
+	assertEqualBoard(t, world.Alive, expected, p)
+
+	err = client.Call("Gol.Submit", gol.Params{Turns: 1, Threads: 1, ImageWidth: 17, ImageHeight: 17}, job)
+	assert(t, err != nil, "Submitting a job for a missing image should fail")
+}
+
+// TestJSONRPCDrain checks that draining refuses new jobs and waits for running ones.
+func TestJSONRPCDrain(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	service := gol.NewService()
+	go serveJSONRPC(listener, service)
+
+	client, err := jsonrpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := gol.Params{Turns: 1000, Threads: 2, ImageWidth: 64, ImageHeight: 64}
+	job := new(gol.JobResponse)
+	if err := client.Call("Gol.Submit", p, job); err != nil {
+		t.Fatal(err)
+	}
+	assert(t, !service.Drain(0), "Drain returned true while a job was running")
+	err = client.Call("Gol.Submit", p, new(gol.JobResponse))
+	assert(t, err != nil && err.Error() == gol.ErrShuttingDown.Error(), "Expected %v for a job submitted while draining, got %v", gol.ErrShuttingDown, err)
+
+	assert(t, service.Drain(10*time.Second), "Job did not finish within 10 seconds of draining")
+	world := new(gol.WorldResponse)
+	if err := client.Call("Gol.World", gol.JobRequest{ID: job.ID}, world); err != nil {
+		t.Fatal(err)
+	}
+	assert(t, world.Done && world.CompletedTurns == p.Turns, "Expected the job to have finished %v turns, got %v (done %v)", p.Turns, world.CompletedTurns, world.Done)
+}
+
+// TestJSONRPCOutputDir checks that a job writes its image to its own folder rather than over the main game's.
+func TestJSONRPCOutputDir(t *testing.T) {
+	emptyOutFolder()
+	service := gol.NewService()
+	job := new(gol.JobResponse)
+	if err := service.Submit(gol.Params{Turns: 50, Threads: 1, ImageWidth: 16, ImageHeight: 16}, job); err != nil {
+		t.Fatal(err)
+	}
+	assert(t, service.Drain(10*time.Second), "Job did not finish in 10 seconds")
+
+	_, err := os.Stat(fmt.Sprintf("out/jobs/%v/16x16x50.pgm", job.ID))
+	assert(t, err == nil, "Job did not write its image to its own folder: %v", err)
+	_, err = os.Stat("out/16x16x50.pgm")
+	assert(t, os.IsNotExist(err), "Job wrote its image to the main game's folder")
+}
+
+// TestSigtermRefusesJobs checks that jobs are refused as soon as a signal arrives, not once the game has ended.
+func TestSigtermRefusesJobs(t *testing.T) {
+	service := gol.NewService()
+	signals := make(chan os.Signal, 1)
+	keyPresses := make(chan rune, 1)
+	go sigterm(signals, keyPresses, service)
+	signals <- syscall.SIGTERM
+
+	select {
+	case key := <-keyPresses:
+		assert(t, key == 'q', "Expected the signal to press 'q', got %q", key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ERROR: The signal did not quit the game")
+	}
+	p := gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16}
+	err := service.Submit(p, new(gol.JobResponse))
+	assert(t, err == gol.ErrShuttingDown, "Expected %v for a job submitted after the signal, got %v", gol.ErrShuttingDown, err)
+}
@@ -23,6 +23,7 @@ func testKeyboardP(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 
 	keyPresses := make(chan rune, 10)
@@ -72,6 +73,7 @@ func testKeyboardS(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 
 	keyPresses := make(chan rune, 10)
@@ -104,6 +106,7 @@ func testKeyboardQ(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 
 	keyPresses := make(chan rune, 10)
@@ -136,6 +139,7 @@ func testKeyboardPS(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 
 	keyPresses := make(chan rune, 10)
@@ -173,6 +177,7 @@ func testKeyboardPQ(t *testing.T) {
 		Threads:     8,
 		ImageWidth:  512,
 		ImageHeight: 512,
+		Mode:        testMode,
 	}
 
 	keyPresses := make(chan rune, 10)
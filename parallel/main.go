@@ -1,15 +1,28 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"net/rpc"
+	"net/rpc/jsonrpc"
 	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
 	"os"
+	"strconv"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/metrics"
+	"uk.ac.bris.cs/gameoflife/remote"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/web"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -17,11 +30,10 @@ func main() {
 	runtime.LockOSThread()
 	var params gol.Params
 
-	flag.IntVar(
-		&params.Threads,
+	threads := flag.String(
 		"t",
-		8,
-		"Specify the number of worker threads to use. Defaults to 8.")
+		"8",
+		"Specify the number of worker threads to use, or auto to tune it while running. Defaults to 8.")
 
 	flag.IntVar(
 		&params.ImageWidth,
@@ -41,34 +53,329 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.Float64Var(
+		&params.VerifyRate,
+		"verify",
+		0,
+		"Specify the fraction of strips to recompute on a second worker to check their checksums. Defaults to 0.")
+
+	mode := flag.String(
+		"mode",
+		"channels",
+		"Specify how workers share the world: channels, shared or stealing. Defaults to channels.")
+
+	kernel := flag.String(
+		"kernel",
+		"cells",
+		"Specify how workers compute cells: cells, one at a time, or blocks, 2x2 at a time from a lookup table. Defaults to cells.")
+
+	flag.BoolVar(
+		&params.Unbounded,
+		"unbounded",
+		false,
+		"Play on an infinite plane instead of wrapping around the edges of the image. Cannot be used with -serve or -http.")
+
+	flag.StringVar(
+		&params.OutOfCore,
+		"outofcore",
+		"",
+		"Keep the world bit-packed in files in this directory, for worlds too big for memory. Implies -headless and cannot be used with -serve or -http.")
+
+	flag.BoolVar(
+		&params.RLE,
+		"rle",
+		false,
+		"Write out-of-core images as RLE patterns instead of PGM images.")
+
 	headless := flag.Bool(
 		"headless",
 		false,
 		"Disable the SDL window for running in a headless environment.")
 
+	jsonPort := flag.String(
+		"jsonrpc",
+		"",
+		"Serve Game of Life jobs over JSON-RPC on this port, e.g. for Python tooling.")
+
+	drainTimeout := flag.Duration(
+		"drain",
+		30*time.Second,
+		"Time to let running JSON-RPC jobs finish once the game has ended or on SIGTERM.")
+
+	servePort := flag.String(
+		"serve",
+		"",
+		"Stream events to remote viewers on this port, see ./viewer.")
+
+	httpPort := flag.String(
+		"http",
+		"",
+		"Serve a live view of the game to browsers on this port.")
+
+	metricsPort := flag.String(
+		"metrics",
+		"",
+		"Serve Prometheus metrics at /metrics on this port.")
+
+	cpuProfile := flag.String(
+		"cpuprofile",
+		"",
+		"Write a CPU profile of the run to this file.")
+
+	memProfile := flag.String(
+		"memprofile",
+		"",
+		"Write a heap profile to this file when the run finishes.")
+
+	traceFile := flag.String(
+		"trace",
+		"",
+		"Write an execution trace of the run to this file, with regions for each turn, worker and IO operation.")
+
+	pprofPort := flag.String(
+		"pprof",
+		"",
+		"Serve /debug/pprof on this port for profiling a run while it is going.")
+
 	flag.Parse()
 
-	fmt.Printf("%-10v %v\n", "Threads", params.Threads)
+	var err error
+	if *threads == "auto" {
+		params.AutoThreads = true
+	} else if params.Threads, err = strconv.Atoi(*threads); err != nil || params.Threads < 1 {
+		fmt.Printf("invalid thread count %q, expected a positive number or auto\n", *threads)
+		return
+	}
+	if params.Mode, err = gol.ParseMode(*mode); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if params.Kernel, err = gol.ParseKernel(*kernel); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if params.OutOfCore != "" && params.Unbounded {
+		fmt.Println("-outofcore and -unbounded cannot be used together")
+		return
+	}
+	if *servePort != "" || *httpPort != "" {
+		if err := checkViewable(params); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	fmt.Printf("%-10v %v\n", "Mode", params.Mode)
+	fmt.Printf("%-10v %v\n", "Kernel", params.Kernel)
+	fmt.Printf("%-10v %v\n", "Threads", *threads)
 	fmt.Printf("%-10v %v\n", "Width", params.ImageWidth)
 	fmt.Printf("%-10v %v\n", "Height", params.ImageHeight)
 	fmt.Printf("%-10v %v\n", "Turns", params.Turns)
+	if params.Unbounded {
+		fmt.Printf("%-10v %v\n", "Plane", "unbounded")
+	}
+	if params.OutOfCore != "" {
+		fmt.Printf("%-10v %v\n", "Storage", params.OutOfCore)
+	}
+
+	stopProfiling, err := startProfiling(*cpuProfile, *traceFile)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer stopProfiling()
+	if *memProfile != "" {
+		defer writeMemProfile(*memProfile)
+	}
+
+	if *pprofPort != "" {
+		listener, err := net.Listen("tcp", ":"+*pprofPort)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer listener.Close()
+		go http.Serve(listener, pprofHandler())
+	}
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
 
-	go sigterm(keyPresses)
+	// Listen for SIGTERM straight away so that it cannot kill the process before the game starts.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	var service *gol.Service
+	if *jsonPort != "" {
+		listener, err := net.Listen("tcp", ":"+*jsonPort)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer listener.Close()
+		service = gol.NewService()
+		go serveJSONRPC(listener, service)
+	}
+
+	go sigterm(signals, keyPresses, service)
+
+	if *metricsPort != "" {
+		listener, err := net.Listen("tcp", ":"+*metricsPort)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer listener.Close()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Default.Handler())
+		go http.Serve(listener, mux)
+	}
 
 	go gol.Run(params, events, keyPresses)
-	if !(*headless) {
+
+	if *servePort != "" || *httpPort != "" {
+		server := remote.NewServer(params, keyPresses)
+		if *servePort != "" {
+			listener, err := net.Listen("tcp", ":"+*servePort)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			defer listener.Close()
+			go server.Accept(listener)
+		}
+		if *httpPort != "" {
+			listener, err := net.Listen("tcp", ":"+*httpPort)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			defer listener.Close()
+			fmt.Printf("Live view at http://localhost:%v/\n", *httpPort)
+			go http.Serve(listener, web.Handler(server))
+		}
+		forwarded := make(chan gol.Event, 1000)
+		go server.Forward(events, forwarded)
+		events = forwarded
+	}
+
+	// An out-of-core game sends no CellFlipped events to draw.
+	if !(*headless) && params.OutOfCore == "" {
 		sdl.Run(params, events, keyPresses)
 	} else {
 		sdl.RunHeadless(events)
 	}
+
+	if service != nil {
+		fmt.Println("Waiting for running jobs to finish")
+		if !service.Drain(*drainTimeout) {
+			fmt.Println("Gave up waiting for running jobs after", *drainTimeout)
+		}
+	}
 }
 
-func sigterm(keyPresses chan<- rune) {
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGTERM, syscall.SIGINT)
-	<-sigterm
+// checkViewable returns an error if remote and web viewers cannot follow a game with
+// these params. The server mirrors the whole world for viewers that join late, and
+// viewers draw a fixed grid the size of the image.
+func checkViewable(p gol.Params) error {
+	if p.OutOfCore != "" {
+		return errors.New("-serve and -http cannot be used with -outofcore, as the world is too big to mirror")
+	}
+	if p.Unbounded {
+		return errors.New("-serve and -http cannot be used with -unbounded, as viewers only show the image's cells")
+	}
+	return nil
+}
+
+// sigterm stops the service taking new jobs and quits the game once a signal arrives.
+func sigterm(signals <-chan os.Signal, keyPresses chan<- rune, service *gol.Service) {
+	<-signals
+	if service != nil {
+		service.Close()
+	}
 	keyPresses <- 'q'
 }
+
+// serveJSONRPC exposes service as "Gol" to JSON-RPC clients until the listener is closed.
+func serveJSONRPC(listener net.Listener, service *gol.Service) {
+	server := rpc.NewServer()
+	err := server.RegisterName("Gol", service)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// startProfiling starts the CPU profile and execution trace that were asked for.
+// The returned function stops them and closes their files.
+func startProfiling(cpuProfile, traceFile string) (stop func(), err error) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	return stop, nil
+}
+
+func writeMemProfile(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer f.Close()
+	// Collect garbage first so the profile shows what is still in use.
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// pprofHandler serves the net/http/pprof pages without registering them on http.DefaultServeMux.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	return mux
+}
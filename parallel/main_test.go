@@ -2,11 +2,13 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"runtime"
 	"testing"
 	"time"
 
+	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -16,14 +18,27 @@ var flipCellChan chan util.Cell
 var refreshChan chan struct{}
 var clearPixelsChan chan struct{}
 
+// testMode is the gol.Mode the tests run in, set with -mode so that every mode can be
+// checked against the same suite, e.g. go test . -mode shared
+var testMode gol.Mode
+
 func TestMain(m *testing.M) {
 	runtime.LockOSThread()
 	var sdlFlag = flag.Bool(
 		"sdl",
 		false,
 		"Enable the SDL window for testing.")
+	var modeFlag = flag.String(
+		"mode",
+		"channels",
+		"Run the tests in this mode: channels, shared or stealing.")
 
 	flag.Parse()
+	var err error
+	if testMode, err = gol.ParseMode(*modeFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 	done := make(chan int, 1)
 	test := func() { done <- m.Run() }
 	if !(*sdlFlag) {
@@ -0,0 +1,227 @@
+// Package metrics records how a game is performing and exposes it in the
+// Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the turn latency histogram.
+var LatencyBuckets = []float64{0.00001, 0.00004, 0.00016, 0.00064, 0.00256, 0.01024, 0.04096, 0.16384, 0.65536, 2.62144}
+
+// rateWindow is how far back the turn rate is averaged over.
+const rateWindow = 5 * time.Second
+
+// Default is the Metrics that games record to.
+var Default = New()
+
+// Metrics is safe to use from many goroutines.
+type Metrics struct {
+	mutex     sync.Mutex
+	turns     int
+	latency   histogram
+	imbalance float64
+	alive     int
+	threads   int
+	ioSeconds map[string]float64
+	samples   []sample
+}
+
+type sample struct {
+	at    time.Time
+	turns int
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func New() *Metrics {
+	m := &Metrics{}
+	m.Reset()
+	return m
+}
+
+// Reset clears every metric, e.g. before a test.
+func (m *Metrics) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.turns = 0
+	m.latency = histogram{counts: make([]uint64, len(LatencyBuckets))}
+	m.imbalance = 0
+	m.alive = 0
+	m.threads = 0
+	m.ioSeconds = map[string]float64{}
+	m.samples = nil
+}
+
+// Turn records a completed turn, how long it took and how long each worker spent on its part.
+func (m *Metrics) Turn(latency time.Duration, workers []time.Duration, alive int) {
+	now := time.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.turns++
+	seconds := latency.Seconds()
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			m.latency.counts[i]++
+		}
+	}
+	m.latency.sum += seconds
+	m.latency.count++
+	m.imbalance = imbalance(workers)
+	m.alive = alive
+
+	// Sampling at most every 100ms keeps the window small however fast turns complete.
+	if len(m.samples) == 0 || now.Sub(m.samples[len(m.samples)-1].at) >= 100*time.Millisecond {
+		m.samples = append(m.samples, sample{at: now, turns: m.turns})
+	} else {
+		m.samples[len(m.samples)-1].turns = m.turns
+	}
+	for len(m.samples) > 2 && now.Sub(m.samples[1].at) > rateWindow {
+		m.samples = m.samples[1:]
+	}
+}
+
+// Threads records how many workers the game is using. A change restarts the turn rate
+// window, so that the rate reflects the new count straight away.
+func (m *Metrics) Threads(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if n != m.threads {
+		m.samples = nil
+	}
+	m.threads = n
+}
+
+// IO records time spent waiting for the io goroutine, op being "input" or "output".
+func (m *Metrics) IO(op string, d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ioSeconds[op] += d.Seconds()
+}
+
+// imbalance is the slowest worker's time over the mean, so 1 is perfectly balanced.
+func imbalance(workers []time.Duration) float64 {
+	if len(workers) == 0 {
+		return 0
+	}
+	var sum, max time.Duration
+	for _, d := range workers {
+		sum += d
+		if d > max {
+			max = d
+		}
+	}
+	if sum == 0 {
+		return 1
+	}
+	return float64(max) * float64(len(workers)) / float64(sum)
+}
+
+// Snapshot is a copy of the metrics at one moment.
+type Snapshot struct {
+	Turns          int
+	TurnsPerSecond float64
+	// LatencyCounts[i] is the number of turns that took at most LatencyBuckets[i] seconds.
+	LatencyCounts []uint64
+	LatencySum    float64
+	LatencyCount  uint64
+	Imbalance     float64
+	Alive         int
+	Threads       int
+	IOSeconds     map[string]float64
+}
+
+func (m *Metrics) Snapshot() Snapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s := Snapshot{
+		Turns:         m.turns,
+		LatencyCounts: append([]uint64(nil), m.latency.counts...),
+		LatencySum:    m.latency.sum,
+		LatencyCount:  m.latency.count,
+		Imbalance:     m.imbalance,
+		Alive:         m.alive,
+		Threads:       m.threads,
+		IOSeconds:     make(map[string]float64, len(m.ioSeconds)),
+	}
+	for op, seconds := range m.ioSeconds {
+		s.IOSeconds[op] = seconds
+	}
+	if n := len(m.samples); n >= 2 {
+		first, last := m.samples[0], m.samples[n-1]
+		if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 {
+			s.TurnsPerSecond = float64(last.turns-first.turns) / elapsed
+		}
+	}
+	return s
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (n int64, err error) {
+	s := m.Snapshot()
+	var lines []string
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	add("# HELP gol_turns_total Turns completed.")
+	add("# TYPE gol_turns_total counter")
+	add("gol_turns_total %v", s.Turns)
+	add("# HELP gol_turns_per_second Turns completed per second over the last %v.", rateWindow)
+	add("# TYPE gol_turns_per_second gauge")
+	add("gol_turns_per_second %v", s.TurnsPerSecond)
+	add("# HELP gol_turn_duration_seconds Time taken to calculate each turn.")
+	add("# TYPE gol_turn_duration_seconds histogram")
+	for i, bound := range LatencyBuckets {
+		add("gol_turn_duration_seconds_bucket{le=\"%v\"} %v", bound, s.LatencyCounts[i])
+	}
+	add("gol_turn_duration_seconds_bucket{le=\"+Inf\"} %v", s.LatencyCount)
+	add("gol_turn_duration_seconds_sum %v", s.LatencySum)
+	add("gol_turn_duration_seconds_count %v", s.LatencyCount)
+	add("# HELP gol_worker_imbalance Slowest worker's time over the mean worker time in the last turn.")
+	add("# TYPE gol_worker_imbalance gauge")
+	add("gol_worker_imbalance %v", s.Imbalance)
+	add("# HELP gol_alive_cells Alive cells after the last turn.")
+	add("# TYPE gol_alive_cells gauge")
+	add("gol_alive_cells %v", s.Alive)
+	add("# HELP gol_threads Workers the game is using.")
+	add("# TYPE gol_threads gauge")
+	add("gol_threads %v", s.Threads)
+	add("# HELP gol_io_seconds_total Time spent waiting for image input and output.")
+	add("# TYPE gol_io_seconds_total counter")
+	ops := make([]string, 0, len(s.IOSeconds))
+	for op := range s.IOSeconds {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		add("gol_io_seconds_total{op=\"%v\"} %v", op, s.IOSeconds[op])
+	}
+
+	for _, line := range lines {
+		written, err := fmt.Fprintln(w, line)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Handler serves the metrics, e.g. at /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.WriteTo(w)
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/metrics"
+)
+
+// TestMetrics runs a game and checks what the metrics recorded, both directly and
+// through the Prometheus endpoint.
+func TestMetrics(t *testing.T) {
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, Mode: testMode}
+	metrics.Default.Reset()
+
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var final gol.FinalTurnComplete
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e
+		}
+	}
+
+	s := metrics.Default.Snapshot()
+	assert(t, s.Turns == p.Turns, "Recorded %v turns, expected %v", s.Turns, p.Turns)
+	assert(t, s.LatencyCount == uint64(p.Turns), "Turn latency histogram has %v turns, expected %v", s.LatencyCount, p.Turns)
+	assert(t, s.LatencyCounts[len(s.LatencyCounts)-1] <= s.LatencyCount, "Largest latency bucket has more turns than were recorded")
+	assert(t, s.Alive == len(final.Alive), "Recorded %v alive cells, expected %v", s.Alive, len(final.Alive))
+	assert(t, s.Imbalance >= 1, "Worker imbalance %v should be at least 1", s.Imbalance)
+	assert(t, s.IOSeconds["input"] > 0 && s.IOSeconds["output"] > 0, "IO time was not recorded: %v", s.IOSeconds)
+
+	server := httptest.NewServer(metrics.Default.Handler())
+	defer server.Close()
+	res, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(body)
+	for _, line := range []string{
+		"gol_turns_total 100",
+		"gol_turn_duration_seconds_count 100",
+		`gol_turn_duration_seconds_bucket{le="+Inf"} 100`,
+		"# TYPE gol_turn_duration_seconds histogram",
+		"gol_alive_cells ",
+		"gol_threads 4",
+		"gol_worker_imbalance ",
+		"gol_turns_per_second ",
+		`gol_io_seconds_total{op="input"} `,
+		`gol_io_seconds_total{op="output"} `,
+	} {
+		assert(t, strings.Contains(text, line), "Metrics do not contain %q:\n%v", line, text)
+	}
+}
+
+// TestMetricsServiceJob checks that a job submitted to the service does not record to the main game's metrics.
+func TestMetricsServiceJob(t *testing.T) {
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, Mode: testMode}
+	metrics.Default.Reset()
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	before := metrics.Default.Snapshot()
+
+	service := gol.NewService()
+	job := new(gol.JobResponse)
+	if err := service.Submit(gol.Params{Turns: 50, Threads: 1, ImageWidth: 16, ImageHeight: 16}, job); err != nil {
+		t.Fatal(err)
+	}
+	assert(t, service.Drain(10*time.Second), "Job did not finish in 10 seconds")
+
+	after := metrics.Default.Snapshot()
+	assert(t, after.Turns == before.Turns, "The job changed the main game's turns from %v to %v", before.Turns, after.Turns)
+	assert(t, after.LatencyCount == before.LatencyCount, "The job changed the main game's latency histogram")
+	assert(t, after.Threads == before.Threads, "The job changed the main game's threads from %v to %v", before.Threads, after.Threads)
+	assert(t, after.Alive == before.Alive, "The job changed the main game's alive cells from %v to %v", before.Alive, after.Alive)
+	assert(t, fmt.Sprint(after.IOSeconds) == fmt.Sprint(before.IOSeconds), "The job changed the main game's IO time")
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	"uk.ac.bris.cs/gameoflife/gol"
@@ -10,24 +13,92 @@ import (
 
 const benchLength = 1000
 
+// The sweep BenchmarkGol runs can be changed with these flags, e.g.
+//
+//	go test -run ^$ -bench ^BenchmarkGol$ . -gol.threads 1,2,4,8 -gol.sizes 64x64,512x512 -gol.modes channels,shared,stealing
+//
+// ./bench runs the sweep repeatedly and summarises the results.
+var (
+	benchThreads = flag.String("gol.threads", "1-16", "Thread counts for BenchmarkGol, as a list such as 1,2,4 and ranges such as 1-16")
+	benchSizes   = flag.String("gol.sizes", "512x512", "Image sizes for BenchmarkGol, e.g. 64x64,512x512")
+	benchTurns   = flag.Int("gol.turns", benchLength, "Turns for each BenchmarkGol run")
+	benchModes   = flag.String("gol.modes", "channels,shared,stealing", "Modes for BenchmarkGol, e.g. channels,shared")
+)
+
 func BenchmarkGol(b *testing.B) {
-	for threads := 1; threads <= 16; threads++ {
-		os.Stdout = nil // Disable all program output apart from benchmark results
-		p := gol.Params{
-			Turns:       benchLength,
-			Threads:     threads,
-			ImageWidth:  512,
-			ImageHeight: 512,
+	threads, err := parseThreads(*benchThreads)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sizes, err := parseSizes(*benchSizes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var modes []gol.Mode
+	for _, name := range strings.Split(*benchModes, ",") {
+		mode, err := gol.ParseMode(name)
+		if err != nil {
+			b.Fatal(err)
 		}
-		name := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
-		b.Run(name, func(b *testing.B) {
-			for i := 0; i < b.N; i++ {
-				events := make(chan gol.Event)
-				go gol.Run(p, events, nil)
-				for range events {
-
+		modes = append(modes, mode)
+	}
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	for _, mode := range modes {
+		for _, size := range sizes {
+			for _, t := range threads {
+				p := gol.Params{
+					Turns:       *benchTurns,
+					Threads:     t,
+					ImageWidth:  size[0],
+					ImageHeight: size[1],
+					Mode:        mode,
 				}
+				name := fmt.Sprintf("mode=%v/size=%dx%d/turns=%d/threads=%d", p.Mode, p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				b.Run(name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						events := make(chan gol.Event)
+						go gol.Run(p, events, nil)
+						for range events {
+
+						}
+					}
+				})
 			}
-		})
+		}
+	}
+}
+
+// parseThreads reads a comma separated list of thread counts and inclusive ranges.
+func parseThreads(s string) ([]int, error) {
+	var threads []int
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread count %q", part)
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid thread range %q", part)
+			}
+		}
+		for t := lo; t <= hi; t++ {
+			threads = append(threads, t)
+		}
+	}
+	return threads, nil
+}
+
+// parseSizes reads a comma separated list of WIDTHxHEIGHT sizes.
+func parseSizes(s string) ([][2]int, error) {
+	var sizes [][2]int
+	for _, part := range strings.Split(s, ",") {
+		var width, height int
+		if _, err := fmt.Sscanf(part, "%dx%d", &width, &height); err != nil {
+			return nil, fmt.Errorf("invalid size %q", part)
+		}
+		sizes = append(sizes, [2]int{width, height})
 	}
+	return sizes, nil
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestOutOfCore tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns with the world
+// kept on disk, checking FinalTurnComplete and the output image, and that the world's files
+// are removed afterwards.
+func TestOutOfCore(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			emptyOutFolder()
+			for _, threads := range []int{1, 3, 16} {
+				p.Threads = threads
+				testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+				t.Run(testName, func(t *testing.T) {
+					p.OutOfCore = t.TempDir()
+					events := make(chan gol.Event)
+					go gol.Run(p, events, nil)
+					var cells []util.Cell
+					for event := range events {
+						switch e := event.(type) {
+						case gol.CellFlipped, gol.CellsFlipped:
+							t.Fatalf("ERROR: Out-of-core game sent %v", e)
+						case gol.FinalTurnComplete:
+							cells = e.Alive
+						}
+					}
+					assertEqualBoard(t, cells, expectedAlive, p)
+					cellsFromImage := readAliveCells(
+						"out/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+						p.ImageWidth,
+						p.ImageHeight,
+					)
+					assertEqualBoard(t, cellsFromImage, expectedAlive, p)
+					files, _ := filepath.Glob(filepath.Join(p.OutOfCore, "*"))
+					assert(t, len(files) == 0, "Files %v were left behind", files)
+				})
+			}
+		}
+	}
+}
+
+// TestOutOfCoreRle writes the 16x16 image's glider as an RLE pattern.
+func TestOutOfCoreRle(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{Turns: 4, Threads: 2, ImageWidth: 16, ImageHeight: 16, OutOfCore: t.TempDir(), RLE: true}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	rle, err := os.ReadFile("out/16x16x4.rle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// After four turns the glider has moved one cell down and right.
+	expected := "x = 16, y = 16, rule = B3/S23\n6$5bo$6bo$4b3o!\n"
+	assert(t, string(rle) == expected, "RLE pattern is %q, expected %q", rle, expected)
+}
@@ -15,6 +15,7 @@ func TestPgm(t *testing.T) {
 		{ImageWidth: 512, ImageHeight: 512},
 	}
 	for _, p := range tests {
+		p.Mode = testMode
 		for _, turns := range []int{0, 1, 100} {
 			p.Turns = turns
 			expectedAlive := readAliveCells(
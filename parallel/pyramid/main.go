@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/deepzoom"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// The pyramid command writes a snapshot of a world, a PGM image or an RLE pattern as the
+// game outputs them, as a Deep Zoom tile pyramid that tiled image viewers can browse, e.g.
+//
+//	go run ./pyramid -in out/512x512x100.pgm -out pyramids
+//
+// The snapshot is read a row at a time, so it may be far bigger than memory.
+func main() {
+	in := flag.String("in", "", "Snapshot to read, a .pgm image or an .rle pattern.")
+	out := flag.String("out", "pyramids", "Directory to write the pyramid into.")
+	tileSize := flag.Int("tile", deepzoom.DefaultTileSize, "Width and height of the tiles.")
+	flag.Parse()
+	if *in == "" {
+		fmt.Println("-in is required")
+		os.Exit(2)
+	}
+
+	name, err := writePyramid(*in, *out, *tileSize)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Pyramid written to", filepath.Join(*out, name+".dzi"))
+}
+
+// writePyramid writes the snapshot at path into dir, named after the snapshot's file.
+func writePyramid(path, dir string, tileSize int) (name string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	r := bufio.NewReaderSize(file, 1<<20)
+	extension := filepath.Ext(path)
+	name = strings.TrimSuffix(filepath.Base(path), extension)
+
+	var width, height int
+	var readRow func() ([]bool, error)
+	switch extension {
+	case ".pgm":
+		width, height, readRow, err = readPgm(r)
+	case ".rle":
+		var pattern *gol.RleReader
+		pattern, err = gol.NewRleReader(r)
+		if err == nil {
+			width, height, readRow = pattern.Width, pattern.Height, pattern.ReadRow
+		}
+	default:
+		err = fmt.Errorf("unknown snapshot format %q, expected .pgm or .rle", extension)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	w, err := deepzoom.NewWriter(dir, name, width, height, tileSize)
+	if err != nil {
+		return "", err
+	}
+	for y := 0; y < height; y++ {
+		cells, err := readRow()
+		if err != nil {
+			return "", err
+		}
+		if err := w.WriteRow(cells); err != nil {
+			return "", err
+		}
+	}
+	return name, w.Close()
+}
+
+// readPgm reads the header of a PGM image and returns a function reading its rows, with
+// any cell that is not black taken as alive.
+func readPgm(r *bufio.Reader) (width, height int, readRow func() ([]bool, error), err error) {
+	var magic string
+	var maxval int
+	if _, err = fmt.Fscan(r, &magic, &width, &height, &maxval); err != nil {
+		return
+	}
+	if magic != "P5" || maxval != 255 {
+		err = fmt.Errorf("expected a P5 image with maxval 255, got %v with maxval %v", magic, maxval)
+		return
+	}
+	// A single whitespace character separates the header from the cells.
+	if _, err = r.ReadByte(); err != nil {
+		return
+	}
+	row := make([]byte, width)
+	cells := make([]bool, width)
+	readRow = func() ([]bool, error) {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		for x, cell := range row {
+			cells[x] = cell != 0
+		}
+		return cells, nil
+	}
+	return
+}
@@ -0,0 +1,304 @@
+// Package remote streams the gol.Event stream over TCP to viewers on other machines
+// and passes their key presses back to the running game.
+package remote
+
+import (
+	"encoding/gob"
+	"net"
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+func init() {
+	// Events are sent as the gol.Event interface, so gob needs to know every concrete type.
+	gob.Register(gol.AliveCellsCount{})
+	gob.Register(gol.ImageOutputComplete{})
+	gob.Register(gol.StateChange{})
+	gob.Register(gol.CellFlipped{})
+	gob.Register(gol.CellsFlipped{})
+	gob.Register(gol.TurnComplete{})
+	gob.Register(gol.FinalTurnComplete{})
+	gob.Register(gol.StripMismatch{})
+	gob.Register(gol.ThreadsChanged{})
+}
+
+// Hello is the first message a viewer receives and describes the world being streamed.
+type Hello struct {
+	Params gol.Params
+}
+
+// message wraps each event so that gob encodes its concrete type.
+type message struct {
+	Event gol.Event
+}
+
+// keyPress is sent from a viewer to the server.
+type keyPress struct {
+	Key rune
+}
+
+// viewerBuffer is how many events a viewer may fall behind by before it is disconnected.
+const viewerBuffer = 10000
+
+type viewer struct {
+	events chan gol.Event
+}
+
+// Server mirrors the world from the events it forwards, so viewers that connect
+// part way through a run are first sent the current state.
+type Server struct {
+	params     gol.Params
+	keyPresses chan<- rune
+
+	mutex   sync.Mutex
+	world   [][]byte
+	turn    int
+	pending []util.Cell // flipped since the last TurnComplete
+	sent    int         // how many of pending have been broadcast
+	viewers map[*viewer]struct{}
+	closed  bool
+	done    chan struct{} // closed once the game has ended and no longer reads key presses
+}
+
+// NewServer returns a server for a game with params p. It keeps a copy of the whole
+// world to send to viewers that join late, so it cannot follow an out-of-core game, and
+// drops cells outside the image, so it cannot follow an unbounded one either.
+func NewServer(p gol.Params, keyPresses chan<- rune) *Server {
+	world := make([][]byte, p.ImageHeight)
+	for i := range world {
+		world[i] = make([]byte, p.ImageWidth)
+	}
+	return &Server{
+		params:     p,
+		keyPresses: keyPresses,
+		world:      world,
+		viewers:    make(map[*viewer]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Accept serves viewers until the listener is closed.
+func (s *Server) Accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+// Forward passes every event on to out, which is closed when events is, and broadcasts it to the viewers.
+// Viewers are sent the cells flipped in a turn as a single CellsFlipped event to keep the stream compact.
+func (s *Server) Forward(events <-chan gol.Event, out chan<- gol.Event) {
+	for event := range events {
+		s.mutex.Lock()
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			s.flip(e.Cell)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				s.flip(cell)
+			}
+		default:
+			s.flush(event.GetCompletedTurns())
+			s.broadcast(event)
+			if _, ok := event.(gol.TurnComplete); ok {
+				s.turn = event.GetCompletedTurns()
+				s.pending = s.pending[:0]
+				s.sent = 0
+			}
+		}
+		s.mutex.Unlock()
+		out <- event
+	}
+	close(out)
+	close(s.done)
+
+	s.mutex.Lock()
+	s.closed = true
+	for v := range s.viewers {
+		s.drop(v)
+	}
+	s.mutex.Unlock()
+}
+
+// flush broadcasts the cells flipped since the last flush. It must be called with the mutex held.
+func (s *Server) flush(turn int) {
+	if s.sent == len(s.pending) {
+		return
+	}
+	cells := append([]util.Cell(nil), s.pending[s.sent:]...)
+	s.sent = len(s.pending)
+	s.broadcast(gol.CellsFlipped{CompletedTurns: turn, Cells: cells})
+}
+
+// broadcast must be called with the mutex held.
+func (s *Server) broadcast(event gol.Event) {
+	for v := range s.viewers {
+		select {
+		case v.events <- event:
+		default:
+			// Too slow to keep up; dropping it is better than stalling the game.
+			s.drop(v)
+		}
+	}
+}
+
+// flip mirrors a flipped cell. Cells outside the image, which only an unbounded game
+// flips, are ignored rather than indexing past the mirror.
+func (s *Server) flip(cell util.Cell) {
+	if cell.X < 0 || cell.Y < 0 || cell.X >= s.params.ImageWidth || cell.Y >= s.params.ImageHeight {
+		return
+	}
+	s.world[cell.Y][cell.X] = ^s.world[cell.Y][cell.X]
+	s.pending = append(s.pending, cell)
+}
+
+// drop must be called with the mutex held.
+func (s *Server) drop(v *viewer) {
+	delete(s.viewers, v)
+	close(v.events)
+}
+
+// Subscribe returns events that bring an empty world up to date, followed by a
+// channel of every event forwarded from now on. The channel is closed when the game
+// ends, when Unsubscribe is called or when the subscriber falls too far behind.
+// ok is false if the game has already ended.
+func (s *Server) Subscribe() (snapshot []gol.Event, events <-chan gol.Event, ok bool) {
+	v := &viewer{events: make(chan gol.Event, viewerBuffer)}
+
+	// Take the snapshot and join the broadcast atomically so no event is missed or repeated.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil, nil, false
+	}
+	// The snapshot is the world at the last completed turn followed by the flips of
+	// the turn in progress that were already broadcast, so the viewer's TurnComplete
+	// matches its world. The rest of the turn's flips will reach it with the next flush.
+	// The input's flips have no TurnComplete of their own, so a cell can be pending twice.
+	pending := make(map[util.Cell]bool, len(s.pending))
+	for _, cell := range s.pending {
+		pending[cell] = !pending[cell]
+	}
+	var alive []util.Cell
+	for y, row := range s.world {
+		for x, cell := range row {
+			if (cell == 255) != pending[util.Cell{X: x, Y: y}] {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	s.viewers[v] = struct{}{}
+	snapshot = []gol.Event{
+		gol.CellsFlipped{CompletedTurns: s.turn, Cells: alive},
+		gol.TurnComplete{CompletedTurns: s.turn},
+		gol.CellsFlipped{CompletedTurns: s.turn + 1, Cells: append([]util.Cell(nil), s.pending[:s.sent]...)},
+	}
+	return snapshot, v.events, true
+}
+
+// Unsubscribe stops events being sent to a channel returned by Subscribe.
+func (s *Server) Unsubscribe(events <-chan gol.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for v := range s.viewers {
+		if v.events == events {
+			s.drop(v)
+		}
+	}
+}
+
+// Params returns the parameters of the game being served.
+func (s *Server) Params() gol.Params {
+	return s.params
+}
+
+// KeyPress passes a key press from a viewer to the game. It reports false, instead of
+// blocking forever, if the game has ended.
+func (s *Server) KeyPress(key rune) bool {
+	select {
+	case s.keyPresses <- key:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	snapshot, events, ok := s.Subscribe()
+	if !ok {
+		return
+	}
+	defer s.Unsubscribe(events)
+
+	go s.readKeys(conn)
+
+	encoder := gob.NewEncoder(conn)
+	if encoder.Encode(Hello{Params: s.params}) != nil {
+		return
+	}
+	for _, event := range snapshot {
+		if encoder.Encode(message{Event: event}) != nil {
+			return
+		}
+	}
+	for event := range events {
+		if encoder.Encode(message{Event: event}) != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) readKeys(conn net.Conn) {
+	decoder := gob.NewDecoder(conn)
+	for {
+		var key keyPress
+		if decoder.Decode(&key) != nil || !s.KeyPress(key.Key) {
+			return
+		}
+	}
+}
+
+// Dial connects to a server. The returned params describe the world, events
+// receives the stream until the connection closes and keys sent on keyPresses
+// are passed back to the game.
+func Dial(address string) (p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, err error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return
+	}
+	decoder := gob.NewDecoder(conn)
+	var hello Hello
+	if err = decoder.Decode(&hello); err != nil {
+		conn.Close()
+		return
+	}
+
+	eventsChan := make(chan gol.Event, 1000)
+	keysChan := make(chan rune, 10)
+	go func() {
+		defer close(eventsChan)
+		for {
+			var m message
+			if decoder.Decode(&m) != nil {
+				return
+			}
+			eventsChan <- m.Event
+		}
+	}()
+	go func() {
+		defer conn.Close()
+		encoder := gob.NewEncoder(conn)
+		for key := range keysChan {
+			if encoder.Encode(keyPress{Key: key}) != nil {
+				return
+			}
+		}
+	}()
+	return hello.Params, eventsChan, keysChan, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/remote"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRemote joins a viewer part way through a run over loopback, checks the
+// world it mirrors against the expected alive counts and quits the game from it.
+func TestRemote(t *testing.T) {
+	p := gol.Params{Turns: 10000, Threads: 8, ImageWidth: 512, ImageHeight: 512, Mode: testMode}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	forwarded := make(chan gol.Event, 1000)
+	server := remote.NewServer(p, keyPresses)
+	go server.Accept(listener)
+	go server.Forward(events, forwarded)
+	go gol.Run(p, events, keyPresses)
+
+	// Let the game get going before the viewer joins.
+	for event := range forwarded {
+		if e, ok := event.(gol.TurnComplete); ok && e.CompletedTurns >= 10 {
+			break
+		}
+	}
+	go func() {
+		for range forwarded {
+		}
+	}()
+
+	viewed, remoteEvents, remoteKeys, err := remote.Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(remoteKeys)
+	assert(t, viewed.ImageWidth == p.ImageWidth && viewed.ImageHeight == p.ImageHeight,
+		"Viewer was told the world is %vx%v, expected %vx%v", viewed.ImageWidth, viewed.ImageHeight, p.ImageWidth, p.ImageHeight)
+
+	world := make([][]bool, p.ImageHeight)
+	for i := range world {
+		world[i] = make([]bool, p.ImageWidth)
+	}
+	checked := 0
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-remoteEvents:
+			if !ok {
+				t.Fatal("ERROR: Viewer was disconnected before the game quit")
+			}
+			switch e := event.(type) {
+			case gol.CellFlipped:
+				world[e.Cell.Y][e.Cell.X] = !world[e.Cell.Y][e.Cell.X]
+			case gol.CellsFlipped:
+				for _, cell := range e.Cells {
+					world[cell.Y][cell.X] = !world[cell.Y][cell.X]
+				}
+			case gol.TurnComplete:
+				expected, ok := alive[e.CompletedTurns]
+				if checked == 5 || !ok {
+					continue
+				}
+				count := 0
+				for _, row := range world {
+					for _, cell := range row {
+						if cell {
+							count++
+						}
+					}
+				}
+				if count != expected {
+					t.Fatalf("ERROR: Viewer has %v alive cells after turn %v, expected %v", count, e.CompletedTurns, expected)
+				}
+				if checked++; checked == 5 {
+					remoteKeys <- 'q'
+				}
+			case gol.StateChange:
+				if e.NewState == gol.Quitting {
+					assert(t, checked == 5, "Game quit before the viewer sent 'q'")
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("ERROR: Viewer did not see the game quit within 10 seconds of sending 'q'")
+		}
+	}
+}
+
+// TestRemoteSnapshotDoubleFlip joins a viewer between two flips of the same cell before
+// the next TurnComplete, as when an input cell dies on the first turn, and checks the
+// viewer's world matches the game's once the turn completes.
+func TestRemoteSnapshotDoubleFlip(t *testing.T) {
+	p := gol.Params{ImageWidth: 4, ImageHeight: 4}
+	server := remote.NewServer(p, make(chan rune))
+	events := make(chan gol.Event)
+	forwarded := make(chan gol.Event)
+	go server.Forward(events, forwarded)
+	send := func(event gol.Event) {
+		events <- event
+		<-forwarded
+	}
+
+	flipped, twice := util.Cell{X: 1, Y: 2}, util.Cell{X: 3, Y: 0}
+	send(gol.CellFlipped{CompletedTurns: 0, Cell: twice})
+	send(gol.CellFlipped{CompletedTurns: 0, Cell: flipped})
+	send(gol.CellFlipped{CompletedTurns: 1, Cell: twice})
+
+	snapshot, viewerEvents, ok := server.Subscribe()
+	if !ok {
+		t.Fatal("ERROR: Could not subscribe to a running game")
+	}
+	send(gol.TurnComplete{CompletedTurns: 1})
+	close(events)
+
+	alive := make(map[util.Cell]bool)
+	flip := func(event gol.Event) {
+		if e, ok := event.(gol.CellsFlipped); ok {
+			for _, cell := range e.Cells {
+				alive[cell] = !alive[cell]
+			}
+		}
+	}
+	for _, event := range snapshot {
+		flip(event)
+	}
+	for event := range viewerEvents {
+		flip(event)
+		if _, ok := event.(gol.TurnComplete); ok {
+			break
+		}
+	}
+	assert(t, alive[flipped] && !alive[twice] && len(alive) <= 2,
+		"Viewer has %v alive after turn 1, expected only %v", alive, flipped)
+}
+
+// TestRemoteKeyPressAfterEnd checks a key press from a viewer does not block once the
+// game has ended and nothing reads key presses.
+func TestRemoteKeyPressAfterEnd(t *testing.T) {
+	server := remote.NewServer(gol.Params{ImageWidth: 4, ImageHeight: 4}, make(chan rune))
+	events := make(chan gol.Event)
+	forwarded := make(chan gol.Event)
+	go server.Forward(events, forwarded)
+	close(events)
+	for range forwarded {
+	}
+
+	done := make(chan bool)
+	go func() { done <- server.KeyPress('q') }()
+	select {
+	case ok := <-done:
+		assert(t, !ok, "Key press was taken after the game ended")
+	case <-time.After(5 * time.Second):
+		t.Fatal("ERROR: Key press blocked after the game ended")
+	}
+}
+
+// TestRemoteOutOfCoreRefused checks that viewers are refused for an out-of-core world,
+// which the server would otherwise mirror in memory.
+func TestRemoteOutOfCoreRefused(t *testing.T) {
+	p := gol.Params{Turns: 1, Threads: 1, ImageWidth: 200000, ImageHeight: 200000, OutOfCore: t.TempDir()}
+	assert(t, checkViewable(p) != nil, "Viewers were allowed for an out-of-core world")
+	p.OutOfCore = ""
+	p.ImageWidth, p.ImageHeight = 16, 16
+	assert(t, checkViewable(p) == nil, "Viewers were refused for an in-memory world")
+}
+
+// TestRemoteUnboundedRefused checks that viewers are refused for an unbounded game, whose
+// cells can leave the grid the viewers draw.
+func TestRemoteUnboundedRefused(t *testing.T) {
+	p := gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16, Unbounded: true}
+	assert(t, checkViewable(p) != nil, "Viewers were allowed for an unbounded game")
+}
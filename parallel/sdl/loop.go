@@ -16,6 +16,18 @@ func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
 	dirty := false
 	refreshTicker := time.NewTicker(time.Second / time.Duration(FPS))
 	avgTurns := util.NewAvgTurns()
+	// An unbounded game can leave the window, so the window follows it.
+	var follow *view
+	if p.Unbounded {
+		follow = newView(w)
+	}
+	flip := func(cell util.Cell) {
+		if follow != nil {
+			follow.flip(cell)
+		} else {
+			w.FlipPixel(cell.X, cell.Y)
+		}
+	}
 
 sdl:
 	for {
@@ -38,6 +50,10 @@ sdl:
 						keyPresses <- 'q'
 					case sdl.K_k:
 						keyPresses <- 'k'
+					case sdl.K_PLUS, sdl.K_EQUALS:
+						keyPresses <- '+'
+					case sdl.K_MINUS:
+						keyPresses <- '-'
 					}
 				}
 			}
@@ -52,12 +68,15 @@ sdl:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				flip(e.Cell)
 			case gol.CellsFlipped:
 				for _, cell := range e.Cells {
-					w.FlipPixel(cell.X, cell.Y) 
+					flip(cell) 
 				}
 			case gol.TurnComplete:
+				if follow != nil {
+					follow.follow()
+				}
 				dirty = true
 			case gol.AliveCellsCount:
 				fmt.Printf("Completed Turns %-8v %-20v Avg%+5v turns/sec\n", event.GetCompletedTurns(), event, avgTurns.Get(event.GetCompletedTurns()))
@@ -65,6 +84,11 @@ sdl:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			case gol.ImageOutputComplete:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.StripMismatch:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			case gol.ThreadsChanged:
+				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+				avgTurns.Reset(event.GetCompletedTurns())
 			case gol.StateChange:
 				fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 				if e.NewState == gol.Quitting {
@@ -85,6 +109,11 @@ func RunHeadless(events <-chan gol.Event) {
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), "Final Turn Complete")
 		case gol.ImageOutputComplete:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.StripMismatch:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+		case gol.ThreadsChanged:
+			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
+			avgTurns.Reset(event.GetCompletedTurns())
 		case gol.StateChange:
 			fmt.Printf("Completed Turns %-8v %v\n", event.GetCompletedTurns(), event)
 			if e.NewState == gol.Quitting {
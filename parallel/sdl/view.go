@@ -0,0 +1,80 @@
+package sdl
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// view shows an unbounded game in a window, following its alive cells as they move
+// beyond the image the game started from.
+type view struct {
+	w *Window
+	// offsetX and offsetY are the cell shown at the window's top left pixel.
+	offsetX, offsetY int
+	alive            map[util.Cell]bool
+}
+
+func newView(w *Window) *view {
+	return &view{w: w, alive: make(map[util.Cell]bool)}
+}
+
+// flip flips a cell, drawing it if it is in the window.
+func (v *view) flip(cell util.Cell) {
+	if v.alive[cell] {
+		delete(v.alive, cell)
+	} else {
+		v.alive[cell] = true
+	}
+	x, y := cell.X-v.offsetX, cell.Y-v.offsetY
+	if x >= 0 && y >= 0 && x < int(v.w.Width) && y < int(v.w.Height) {
+		v.w.FlipPixel(x, y)
+	}
+}
+
+// follow moves the window after a turn if the alive cells have left it. The window is
+// centred on the cells, and if they do not fit it only moves once their centre has
+// drifted by a quarter of the window, so that it does not jump every turn.
+func (v *view) follow() {
+	if len(v.alive) == 0 {
+		return
+	}
+	first := true
+	var minX, minY, maxX, maxY int
+	for cell := range v.alive {
+		if first || cell.X < minX {
+			minX = cell.X
+		}
+		if first || cell.X > maxX {
+			maxX = cell.X
+		}
+		if first || cell.Y < minY {
+			minY = cell.Y
+		}
+		if first || cell.Y > maxY {
+			maxY = cell.Y
+		}
+		first = false
+	}
+	width, height := int(v.w.Width), int(v.w.Height)
+	if minX >= v.offsetX && minY >= v.offsetY && maxX < v.offsetX+width && maxY < v.offsetY+height {
+		return
+	}
+	offsetX := (minX+maxX+1)/2 - width/2
+	offsetY := (minY+maxY+1)/2 - height/2
+	fits := maxX-minX < width && maxY-minY < height
+	if !fits && abs(offsetX-v.offsetX) < width/4 && abs(offsetY-v.offsetY) < height/4 {
+		return
+	}
+	v.offsetX, v.offsetY = offsetX, offsetY
+	v.w.ClearPixels()
+	for cell := range v.alive {
+		x, y := cell.X-v.offsetX, cell.Y-v.offsetY
+		if x >= 0 && y >= 0 && x < width && y < height {
+			v.w.SetPixel(x, y)
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
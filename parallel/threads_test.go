@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/metrics"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestThreads presses '+' and '-' while a game is running and checks the ThreadsChanged
+// events, the threads metric and that the final board is unaffected.
+func TestThreads(t *testing.T) {
+	p := gol.Params{Turns: 100, Threads: 2, ImageWidth: 512, ImageHeight: 512, Mode: testMode}
+	metrics.Default.Reset()
+
+	keyPresses := make(chan rune, 10)
+	// The second '-' at one thread is ignored.
+	for _, key := range "++----+" {
+		keyPresses <- key
+	}
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, keyPresses)
+
+	var threads []int
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ThreadsChanged:
+			threads = append(threads, e.Threads)
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	expected := []int{3, 4, 3, 2, 1, 2}
+	assert(t, fmt.Sprint(threads) == fmt.Sprint(expected), "Threads changed to %v, expected %v", threads, expected)
+	s := metrics.Default.Snapshot()
+	assert(t, s.Threads == 2, "Threads metric is %v, expected 2", s.Threads)
+	assertEqualBoard(t, cells, readAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight), p)
+}
+
+// TestThreadsJSONRPC changes a job's thread count over JSON-RPC.
+func TestThreadsJSONRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveJSONRPC(listener, gol.NewService())
+
+	client, err := jsonrpc.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 512, ImageHeight: 512}
+	job := new(gol.JobResponse)
+	if err := client.Call("Gol.Submit", p, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call("Gol.Threads", gol.ThreadsRequest{ID: job.ID, Delta: -2}, job); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Call("Gol.Threads", gol.ThreadsRequest{ID: job.ID, Delta: 1000}, job)
+	assert(t, err != nil, "Changing by more threads than can be queued should fail")
+
+	world := new(gol.WorldResponse)
+	deadline := time.Now().Add(30 * time.Second)
+	for !world.Done {
+		if time.Now().After(deadline) {
+			t.Fatal("ERROR: Job did not finish in 30 seconds")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if err := client.Call("Gol.World", gol.JobRequest{ID: job.ID}, world); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert(t, world.Threads == 2, "Job has %v threads, expected 2", world.Threads)
+	expected := readAliveCells(fmt.Sprintf("check/images/%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns), p.ImageWidth, p.ImageHeight)
+	assertEqualBoard(t, world.Alive, expected, p)
+
+	err = client.Call("Gol.Threads", gol.ThreadsRequest{ID: job.ID, Delta: 1}, job)
+	assert(t, err != nil, "Changing the threads of a finished job should fail")
+}
+
+// TestAutoThreads runs a game that tunes its own thread count and checks that the tuner
+// tried more than one count without affecting the final board.
+func TestAutoThreads(t *testing.T) {
+	p := gol.Params{Turns: 100, AutoThreads: true, ImageWidth: 512, ImageHeight: 512, Mode: testMode}
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, nil)
+
+	changes := 0
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ThreadsChanged:
+			changes++
+			assert(t, e.Threads >= 1, "Tuner chose %v threads", e.Threads)
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	assert(t, changes > 0, "Tuner never changed the thread count")
+	assertEqualBoard(t, cells, readAliveCells("check/images/512x512x100.pgm", p.ImageWidth, p.ImageHeight), p)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"runtime/trace"
 	"testing"
 	"uk.ac.bris.cs/gameoflife/gol"
@@ -15,6 +18,7 @@ func TestTrace(t *testing.T) {
 		Threads:     4,
 		ImageWidth:  64,
 		ImageHeight: 64,
+		Mode:        testMode,
 	}
 	f, _ := os.Create("trace.out")
 	events := make(chan gol.Event)
@@ -27,3 +31,50 @@ func TestTrace(t *testing.T) {
 	err = f.Close()
 	util.Check(err)
 }
+
+// TestTraceRegions checks that a trace of a run contains the run task and the turn, worker
+// and IO regions, each begun and ended the same number of times. The trace is parsed by
+// the toolchain's own trace tool rather than by reading its wire format.
+func TestTraceRegions(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is needed to parse the trace")
+	}
+	p := gol.Params{Turns: 10, Threads: 4, ImageWidth: 64, ImageHeight: 64, Mode: testMode}
+	path := filepath.Join(t.TempDir(), "trace.out")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trace.Start(f); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	for range events {
+	}
+	trace.Stop()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := exec.Command(goTool, "tool", "trace", "-d=parsed", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ERROR: go tool trace could not parse the trace: %v\n%s", err, dump)
+	}
+	counts := make(map[string]int)
+	kind := regexp.MustCompile(`\b(TaskBegin|RegionBegin|RegionEnd)\b.*\bType="([^"]*)"`)
+	for _, match := range kind.FindAllStringSubmatch(string(dump), -1) {
+		counts[match[1]+" "+match[2]]++
+	}
+
+	assert(t, counts["TaskBegin gol: run"] == 1, "Expected 1 %q task, got %v", "gol: run", counts["TaskBegin gol: run"])
+	expected := map[string]int{"gol: turn": p.Turns, "gol: input": 1, "gol: output": 1}
+	for name, n := range expected {
+		begun, ended := counts["RegionBegin "+name], counts["RegionEnd "+name]
+		assert(t, begun == n && ended == n, "Expected %v %q regions, got %v begun and %v ended", n, name, begun, ended)
+	}
+	// How many worker regions a turn has depends on the mode, but there is at least one.
+	begun, ended := counts["RegionBegin gol: worker"], counts["RegionEnd gol: worker"]
+	assert(t, begun >= p.Turns && begun == ended, "Expected at least %v %q regions, got %v begun and %v ended", p.Turns, "gol: worker", begun, ended)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestUnbounded runs the 16x16 image on an unbounded plane, where its glider leaves the
+// image instead of wrapping around, and checks the final image is the bounding box of the
+// alive cells reported by FinalTurnComplete.
+func TestUnbounded(t *testing.T) {
+	emptyOutFolder()
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 16, ImageHeight: 16, Unbounded: true}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	var filename string
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		case gol.ImageOutputComplete:
+			filename = e.Filename
+		}
+	}
+
+	// The glider moves a cell down and right every four turns.
+	var expectedAlive []util.Cell
+	for _, cell := range readAliveCells("images/16x16.pgm", 16, 16) {
+		expectedAlive = append(expectedAlive, util.Cell{X: cell.X + p.Turns/4, Y: cell.Y + p.Turns/4})
+	}
+	if fmt.Sprint(cells) != fmt.Sprint(expectedAlive) {
+		t.Fatalf("ERROR: Alive cells are %v, expected %v", cells, expectedAlive)
+	}
+	minX, minY, maxX, maxY := cells[0].X, cells[0].Y, cells[0].X, cells[0].Y
+	for _, cell := range cells {
+		minX, maxX = min(minX, cell.X), max(maxX, cell.X)
+		minY, maxY = min(minY, cell.Y), max(maxY, cell.Y)
+	}
+
+	width, height := maxX-minX+1, maxY-minY+1
+	expected := fmt.Sprintf("%vx%vx%v", height, width, p.Turns)
+	assert(t, filename == expected, "Image is %v, expected %v", filename, expected)
+	if _, err := os.Stat("out/" + expected + ".pgm"); err != nil {
+		t.Fatal(err)
+	}
+	image := readAliveCells("out/"+expected+".pgm", width, height)
+	var shifted []util.Cell
+	for _, cell := range cells {
+		shifted = append(shifted, util.Cell{X: cell.X - minX, Y: cell.Y - minY})
+	}
+	assertEqualBoard(t, image, shifted, gol.Params{ImageWidth: width, ImageHeight: height})
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
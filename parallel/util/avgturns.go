@@ -47,3 +47,15 @@ func (avg *AvgTurns) Get(completedTurns int) (avgTurns int) {
 	avgTurns = int(sumTurns) / int(math.Round(math.Max(sumDurations.Seconds(), 1)))
 	return avgTurns
 }
+
+// Reset forgets the turns recorded so far, e.g. after the number of workers changes,
+// so that the average only covers turns from completedTurns onwards.
+func (avg *AvgTurns) Reset(completedTurns int) {
+	avg.mutex.Lock()
+	defer avg.mutex.Unlock()
+	avg.count = 0
+	avg.lastCompleteTurns = completedTurns
+	avg.lastCalled = time.Now()
+	avg.bufTurns = [BUF_SIZE]int{}
+	avg.bufDurations = [BUF_SIZE]time.Duration{}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestVerify checks that verifying every strip leaves the results unchanged and raises no false alarms.
+func TestVerify(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+	}
+	for _, p := range tests {
+		p.Mode = testMode
+		p.Turns = 100
+		p.VerifyRate = 1
+		expectedAlive := readAliveCells(
+			"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, p.Turns),
+			p.ImageWidth,
+			p.ImageHeight,
+		)
+		for _, threads := range []int{1, 2, 5, 16, 37, 64} {
+			p.Threads = threads
+			testName := fmt.Sprintf("%dx%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Turns, p.Threads)
+			t.Run(testName, func(t *testing.T) {
+				events := make(chan gol.Event)
+				go gol.Run(p, events, nil)
+				var cells []util.Cell
+				for event := range events {
+					switch e := event.(type) {
+					case gol.StripMismatch:
+						t.Errorf("ERROR: Unexpected %v", e)
+					case gol.FinalTurnComplete:
+						cells = e.Alive
+					}
+				}
+				assertEqualBoard(t, cells, expectedAlive, p)
+			})
+		}
+	}
+}
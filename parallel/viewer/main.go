@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"uk.ac.bris.cs/gameoflife/remote"
+	"uk.ac.bris.cs/gameoflife/sdl"
+)
+
+// main connects to a Game of Life started with -serve and renders it in an SDL window.
+// Key presses in the window are sent back to control the game, e.g.
+//
+//	go run . -serve 8040
+//	go run ./viewer -server 127.0.0.1:8040
+func main() {
+	runtime.LockOSThread()
+	server := flag.String(
+		"server",
+		"127.0.0.1:8040",
+		"Specify the IP:port of the Game of Life to view. Defaults to 127.0.0.1:8040.")
+
+	headless := flag.Bool(
+		"headless",
+		false,
+		"Disable the SDL window and only print the events.")
+
+	flag.Parse()
+
+	params, events, keyPresses, err := remote.Dial(*server)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-10v %v\n", "Width", params.ImageWidth)
+	fmt.Printf("%-10v %v\n", "Height", params.ImageHeight)
+
+	if !(*headless) {
+		sdl.Run(params, events, keyPresses)
+	} else {
+		sdl.RunHeadless(events)
+	}
+	close(keyPresses)
+}
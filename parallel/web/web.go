@@ -0,0 +1,156 @@
+// Package web serves a live view of the game to browsers, for machines without SDL2.
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/remote"
+)
+
+//go:embed index.html
+var page []byte
+
+// Update is a message pushed to the browser as a Server-Sent Event.
+type Update struct {
+	// Type is "hello", "turn", "state" or "log".
+	Type   string   `json:"type"`
+	Turn   int      `json:"turn"`
+	Width  int      `json:"width,omitempty"`
+	Height int      `json:"height,omitempty"`
+	Cells  [][2]int `json:"cells,omitempty"`
+	State  string   `json:"state,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+// keys are the key presses the page's buttons may send.
+var keys = map[string]rune{"p": 'p', "s": 's', "q": 'q', "k": 'k', "+": '+', "-": '-'}
+
+// Handler serves the page at /, the live updates at /events and accepts key presses
+// as POST /key?key=p. Flipped cells are collected and pushed once per turn.
+// Key presses from pages served by other sites are refused.
+func Handler(server *remote.Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(server, w, r)
+	})
+	mux.HandleFunc("/key", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "key presses must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "key presses must come from the page itself", http.StatusForbidden)
+			return
+		}
+		key, ok := keys[r.FormValue("key")]
+		if !ok {
+			http.Error(w, "unknown key", http.StatusBadRequest)
+			return
+		}
+		if !server.KeyPress(key) {
+			http.Error(w, "the game has ended", http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// sameOrigin reports whether r was sent by a page from this server. Browsers set Origin
+// on every POST, so a request without one comes from some other kind of client.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func serveEvents(server *remote.Server, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	snapshot, events, ok := server.Subscribe()
+	if !ok {
+		http.Error(w, "the game has finished", http.StatusGone)
+		return
+	}
+	defer server.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	p := server.Params()
+	if send(w, Update{Type: "hello", Width: p.ImageWidth, Height: p.ImageHeight}) != nil {
+		return
+	}
+
+	var flipped [][2]int
+	handle := func(event gol.Event) error {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			flipped = append(flipped, [2]int{e.Cell.X, e.Cell.Y})
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				flipped = append(flipped, [2]int{cell.X, cell.Y})
+			}
+		case gol.TurnComplete:
+			err := send(w, Update{Type: "turn", Turn: e.CompletedTurns, Cells: flipped})
+			flipped = flipped[:0]
+			return err
+		case gol.StateChange:
+			return send(w, Update{Type: "state", Turn: e.CompletedTurns, State: e.NewState.String()})
+		case gol.AliveCellsCount, gol.ImageOutputComplete, gol.FinalTurnComplete, gol.StripMismatch, gol.ThreadsChanged:
+			return send(w, Update{Type: "log", Turn: event.GetCompletedTurns(), Text: fmt.Sprint(event)})
+		}
+		return nil
+	}
+
+	for _, event := range snapshot {
+		if handle(event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if handle(event) != nil {
+				return
+			}
+			// Only flush once the backlog is written so a busy game sends fewer, larger writes.
+			if len(events) == 0 {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func send(w http.ResponseWriter, u Update) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
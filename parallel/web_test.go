@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/remote"
+	"uk.ac.bris.cs/gameoflife/web"
+)
+
+// TestWeb follows the browser viewer's event stream, checks the world it builds
+// against the expected alive counts and quits the game with the page's key endpoint.
+func TestWeb(t *testing.T) {
+	p := gol.Params{Turns: 10000, Threads: 8, ImageWidth: 512, ImageHeight: 512, Mode: testMode}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+
+	keyPresses := make(chan rune, 10)
+	events := make(chan gol.Event, 1000)
+	forwarded := make(chan gol.Event, 1000)
+	server := remote.NewServer(p, keyPresses)
+	go server.Forward(events, forwarded)
+	go gol.Run(p, events, keyPresses)
+	go func() {
+		for range forwarded {
+		}
+	}()
+
+	httpServer := httptest.NewServer(web.Handler(server))
+	defer httpServer.Close()
+
+	page, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Body.Close()
+	assert(t, page.StatusCode == http.StatusOK, "Page returned %v", page.Status)
+
+	res, err := http.Get(httpServer.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assert(t, res.Header.Get("Content-Type") == "text/event-stream", "Events have content type %q", res.Header.Get("Content-Type"))
+
+	updates := make(chan web.Update, 1000)
+	go func() {
+		defer close(updates)
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(nil, 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var u web.Update
+			// A partial line is only read once the test has finished and closed the body.
+			if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &u) != nil {
+				return
+			}
+			updates <- u
+		}
+	}()
+
+	var world [][]bool
+	checked := 0
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				t.Fatal("ERROR: Event stream ended before the game quit")
+			}
+			switch u.Type {
+			case "hello":
+				world = make([][]bool, u.Height)
+				for i := range world {
+					world[i] = make([]bool, u.Width)
+				}
+			case "turn":
+				for _, cell := range u.Cells {
+					world[cell[1]][cell[0]] = !world[cell[1]][cell[0]]
+				}
+				expected, ok := alive[u.Turn]
+				if checked == 5 || !ok {
+					continue
+				}
+				count := 0
+				for _, row := range world {
+					for _, cell := range row {
+						if cell {
+							count++
+						}
+					}
+				}
+				if count != expected {
+					t.Fatalf("ERROR: Browser has %v alive cells after turn %v, expected %v", count, u.Turn, expected)
+				}
+				if checked++; checked == 5 {
+					res, err := postKey(httpServer.URL, httpServer.URL, "q")
+					if err != nil {
+						t.Fatal(err)
+					}
+					res.Body.Close()
+					assert(t, res.StatusCode == http.StatusNoContent, "Key press returned %v", res.Status)
+				}
+			case "state":
+				if u.State == gol.Quitting.String() {
+					assert(t, checked == 5, "Game quit before the quit button was pressed")
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("ERROR: Browser did not see the game quit within 10 seconds")
+		}
+	}
+}
+
+// postKey presses key as a page served from origin would.
+func postKey(serverURL, origin, key string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, serverURL+"/key", strings.NewReader(url.Values{"key": {key}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", origin)
+	return http.DefaultClient.Do(req)
+}
+
+// TestWebForeignOrigin checks that a page on another site cannot press keys.
+func TestWebForeignOrigin(t *testing.T) {
+	keyPresses := make(chan rune, 10)
+	server := remote.NewServer(gol.Params{Turns: 1, Threads: 1, ImageWidth: 16, ImageHeight: 16}, keyPresses)
+	httpServer := httptest.NewServer(web.Handler(server))
+	defer httpServer.Close()
+
+	res, err := postKey(httpServer.URL, "http://attacker.example", "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert(t, res.StatusCode == http.StatusForbidden, "Key press from another origin returned %v", res.Status)
+	assert(t, len(keyPresses) == 0, "Key press from another origin reached the game")
+}
@@ -0,0 +1,285 @@
+// Package wire encodes strips of a world compactly for sending over RPC.
+// Nothing in this tree sends strips yet; it is meant for a distributed version
+// where workers send their part of the world back each turn.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Encoding identifies how the cells of a Strip are stored in its Data.
+type Encoding uint8
+
+const (
+	// Raw stores one byte per cell, exactly as in the world.
+	Raw Encoding = iota
+	// BitPacked stores one bit per cell, row by row, with each row padded to a whole byte.
+	BitPacked
+	// RunLength stores the lengths of alternating dead and alive runs as uvarints,
+	// reading the strip row by row and starting with a (possibly empty) dead run.
+	RunLength
+	// Delta stores only the rows that changed since the previous strip, with their
+	// indices within the strip listed in Rows. The cells that flipped in those rows
+	// are run length encoded as for RunLength, with alive meaning flipped.
+	Delta
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case Raw:
+		return "Raw"
+	case BitPacked:
+		return "BitPacked"
+	case RunLength:
+		return "RunLength"
+	case Delta:
+		return "Delta"
+	default:
+		return "Incorrect Encoding"
+	}
+}
+
+// Strip is the rows [StartY, StartY+Height) of a world in a form that can be sent over RPC.
+type Strip struct {
+	StartY   int
+	Width    int
+	Height   int
+	Encoding Encoding
+	Rows     []int
+	Data     []byte
+}
+
+// Encode encodes rows [startY, endY) of world. The previous world is only used,
+// and must be the receiver's copy, for the Delta encoding.
+func Encode(world [][]byte, startY, endY int, encoding Encoding, previous [][]byte) Strip {
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	strip := Strip{StartY: startY, Width: width, Height: endY - startY, Encoding: encoding}
+	rows := world[startY:endY]
+	switch encoding {
+	case Raw:
+		strip.Data = make([]byte, 0, strip.Height*width)
+		for _, row := range rows {
+			strip.Data = append(strip.Data, row...)
+		}
+	case BitPacked:
+		strip.Data = make([]byte, 0, strip.Height*rowBytes(width))
+		for _, row := range rows {
+			strip.Data = packRow(strip.Data, row)
+		}
+	case RunLength:
+		strip.Data = runLength(rows)
+	case Delta:
+		var flipped [][]byte
+		for y, row := range rows {
+			if !equalRows(row, previous[startY+y]) {
+				strip.Rows = append(strip.Rows, y)
+				flipped = append(flipped, xorRow(row, previous[startY+y]))
+			}
+		}
+		strip.Data = runLength(flipped)
+	}
+	return strip
+}
+
+// Smallest tries every encoding and returns the strip with the least data.
+// Delta is only considered when previous is not nil.
+func Smallest(world [][]byte, startY, endY int, previous [][]byte) Strip {
+	best := Encode(world, startY, endY, BitPacked, nil)
+	candidates := []Encoding{RunLength}
+	if previous != nil {
+		candidates = append(candidates, Delta)
+	}
+	for _, encoding := range candidates {
+		strip := Encode(world, startY, endY, encoding, previous)
+		if strip.Size() < best.Size() {
+			best = strip
+		}
+	}
+	return best
+}
+
+// Size is the approximate number of payload bytes the strip puts on the wire.
+func (s Strip) Size() int {
+	return len(s.Data) + 8*len(s.Rows)
+}
+
+// Decode writes the strip's rows into world, which must already have the full
+// world's dimensions. For the Delta encoding world must hold the previous state,
+// as rows that did not change are left untouched.
+func Decode(s Strip, world [][]byte) error {
+	if s.Width < 0 || s.Height < 0 {
+		return fmt.Errorf("strip size %vx%v is negative", s.Width, s.Height)
+	}
+	if s.StartY < 0 || s.StartY+s.Height > len(world) {
+		return fmt.Errorf("strip rows %v to %v do not fit in a world of height %v", s.StartY, s.StartY+s.Height, len(world))
+	}
+	for y := s.StartY; y < s.StartY+s.Height; y++ {
+		if len(world[y]) != s.Width {
+			return fmt.Errorf("strip width %v does not match world width %v", s.Width, len(world[y]))
+		}
+	}
+	rows := world[s.StartY : s.StartY+s.Height]
+	data := s.Data
+	switch s.Encoding {
+	case Raw:
+		if len(data) != s.Height*s.Width {
+			return errors.New("raw strip has the wrong amount of data")
+		}
+		if !validCells(data) {
+			return errors.New("raw strip has cells that are neither 0 nor 255")
+		}
+		for _, row := range rows {
+			data = data[copy(row, data):]
+		}
+	case BitPacked:
+		if len(data) != s.Height*rowBytes(s.Width) {
+			return errors.New("bit packed strip has the wrong amount of data")
+		}
+		for _, row := range rows {
+			data = unpackRow(data, row)
+		}
+	case RunLength:
+		return unRunLength(data, rows)
+	case Delta:
+		flipped := make([][]byte, len(s.Rows))
+		for i, y := range s.Rows {
+			if y < 0 || y >= s.Height {
+				return fmt.Errorf("delta row %v is outside the strip", y)
+			}
+			// Flipping any other value would not give an alive or dead cell.
+			if !validCells(rows[y]) {
+				return fmt.Errorf("delta row %v has cells that are neither 0 nor 255", y)
+			}
+			flipped[i] = make([]byte, s.Width)
+		}
+		if err := unRunLength(data, flipped); err != nil {
+			return err
+		}
+		for i, y := range s.Rows {
+			copy(rows[y], xorRow(rows[y], flipped[i]))
+		}
+	default:
+		return fmt.Errorf("unknown encoding %v", s.Encoding)
+	}
+	return nil
+}
+
+// validCells reports whether every cell is either dead (0) or alive (255).
+func validCells(cells []byte) bool {
+	for _, cell := range cells {
+		if cell != 0 && cell != 255 {
+			return false
+		}
+	}
+	return true
+}
+
+func rowBytes(width int) int {
+	return (width + 7) / 8
+}
+
+func packRow(data []byte, row []byte) []byte {
+	for x := 0; x < len(row); x += 8 {
+		var packed byte
+		for bit := 0; bit < 8 && x+bit < len(row); bit++ {
+			if row[x+bit] == 255 {
+				packed |= 1 << uint(bit)
+			}
+		}
+		data = append(data, packed)
+	}
+	return data
+}
+
+// unpackRow fills row from the start of data and returns the rest of data.
+func unpackRow(data []byte, row []byte) []byte {
+	for x := range row {
+		if data[x/8]&(1<<uint(x%8)) != 0 {
+			row[x] = 255
+		} else {
+			row[x] = 0
+		}
+	}
+	return data[rowBytes(len(row)):]
+}
+
+func equalRows(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func xorRow(a, b []byte) []byte {
+	xor := make([]byte, len(a))
+	for i := range a {
+		xor[i] = a[i] ^ b[i]
+	}
+	return xor
+}
+
+func runLength(rows [][]byte) []byte {
+	var data []byte
+	var buf [binary.MaxVarintLen64]byte
+	current := byte(0)
+	run := uint64(0)
+	for _, row := range rows {
+		for _, cell := range row {
+			if cell != current {
+				data = append(data, buf[:binary.PutUvarint(buf[:], run)]...)
+				current = cell
+				run = 0
+			}
+			run++
+		}
+	}
+	return append(data, buf[:binary.PutUvarint(buf[:], run)]...)
+}
+
+func unRunLength(data []byte, rows [][]byte) error {
+	current := byte(0)
+	y, x := 0, 0
+	// Rows of width 0 hold no cells, so they are skipped over rather than filled.
+	skipEmpty := func() {
+		for y < len(rows) && len(rows[y]) == 0 {
+			y++
+		}
+	}
+	skipEmpty()
+	for first := true; len(data) > 0; first = false {
+		run, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("run length strip is corrupted")
+		}
+		// Only the first dead run may be empty, so runs always alternate between 0 and 255.
+		if run == 0 && !first {
+			return errors.New("run length strip has an empty run")
+		}
+		data = data[n:]
+		for ; run > 0; run-- {
+			if y >= len(rows) {
+				return errors.New("run length strip has too many cells")
+			}
+			rows[y][x] = current
+			if x++; x == len(rows[y]) {
+				x, y = 0, y+1
+				skipEmpty()
+			}
+		}
+		current = ^current
+	}
+	if y != len(rows) {
+		return errors.New("run length strip has too few cells")
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+	"uk.ac.bris.cs/gameoflife/wire"
+)
+
+var encodings = []wire.Encoding{wire.Raw, wire.BitPacked, wire.RunLength, wire.Delta}
+
+func cellsToWorld(cells []util.Cell, width, height int) [][]byte {
+	world := make([][]byte, height)
+	for i := range world {
+		world[i] = make([]byte, width)
+	}
+	for _, cell := range cells {
+		world[cell.Y][cell.X] = 255
+	}
+	return world
+}
+
+func copyWorld(world [][]byte) [][]byte {
+	copied := make([][]byte, len(world))
+	for i := range world {
+		copied[i] = append([]byte(nil), world[i]...)
+	}
+	return copied
+}
+
+// TestWire checks that every encoding survives a round trip for the check images split into strips.
+func TestWire(t *testing.T) {
+	for _, size := range []int{16, 64, 512} {
+		previous := cellsToWorld(readAliveCells(fmt.Sprintf("check/images/%vx%vx0.pgm", size, size), size, size), size, size)
+		current := cellsToWorld(readAliveCells(fmt.Sprintf("check/images/%vx%vx1.pgm", size, size), size, size), size, size)
+		for _, encoding := range encodings {
+			for _, strips := range []int{1, 3, 16} {
+				t.Run(fmt.Sprintf("%dx%d-%v-%d", size, size, encoding, strips), func(t *testing.T) {
+					received := copyWorld(previous)
+					for i := 0; i < strips; i++ {
+						strip := wire.Encode(current, i*size/strips, (i+1)*size/strips, encoding, previous)
+						if err := wire.Decode(strip, received); err != nil {
+							t.Fatal(err)
+						}
+					}
+					for y := range current {
+						if !bytes.Equal(current[y], received[y]) {
+							t.Fatalf("ERROR: Row %v differs after decoding", y)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestWireZeroWidth checks that every encoding survives a round trip for a world with no columns.
+func TestWireZeroWidth(t *testing.T) {
+	world := cellsToWorld(nil, 0, 4)
+	for _, encoding := range encodings {
+		strip := wire.Encode(world, 1, 3, encoding, world)
+		if err := wire.Decode(strip, copyWorld(world)); err != nil {
+			t.Errorf("ERROR: %v strip of width 0 failed to decode: %v", encoding, err)
+		}
+	}
+}
+
+// collectWorlds runs the game and returns a copy of the world after every turn, starting with turn 0.
+func collectWorlds(p gol.Params) [][][]byte {
+	world := cellsToWorld(nil, p.ImageWidth, p.ImageHeight)
+	var worlds [][][]byte
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, nil)
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			world[e.Cell.Y][e.Cell.X] = ^world[e.Cell.Y][e.Cell.X]
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				world[cell.Y][cell.X] = ^world[cell.Y][cell.X]
+			}
+		case gol.StateChange:
+			if e.NewState == gol.Executing && len(worlds) == 0 {
+				worlds = append(worlds, copyWorld(world))
+			}
+		case gol.TurnComplete:
+			worlds = append(worlds, copyWorld(world))
+		}
+	}
+	return worlds
+}
+
+func gobSize(b *testing.B, v interface{}) int {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Len()
+}
+
+// BenchmarkWireBytes reports the gob encoded bytes on the wire per turn when the
+// 512x512 world is sent back from 8 workers as strips, compared with sending [][]byte.
+func BenchmarkWireBytes(b *testing.B) {
+	p := gol.Params{Turns: 100, Threads: 8, ImageWidth: 512, ImageHeight: 512, Mode: testMode}
+	worlds := collectWorlds(p)
+	strips := p.Threads
+
+	b.Run("Slices", func(b *testing.B) {
+		total := 0
+		for i := 0; i < b.N; i++ {
+			for turn := 1; turn < len(worlds); turn++ {
+				for s := 0; s < strips; s++ {
+					total += gobSize(b, worlds[turn][s*p.ImageHeight/strips:(s+1)*p.ImageHeight/strips])
+				}
+			}
+		}
+		b.ReportMetric(float64(total)/float64(b.N*(len(worlds)-1)), "B/turn")
+	})
+	for _, encoding := range encodings {
+		b.Run(encoding.String(), func(b *testing.B) {
+			total := 0
+			for i := 0; i < b.N; i++ {
+				for turn := 1; turn < len(worlds); turn++ {
+					for s := 0; s < strips; s++ {
+						strip := wire.Encode(worlds[turn], s*p.ImageHeight/strips, (s+1)*p.ImageHeight/strips, encoding, worlds[turn-1])
+						total += gobSize(b, strip)
+					}
+				}
+			}
+			b.ReportMetric(float64(total)/float64(b.N*(len(worlds)-1)), "B/turn")
+		})
+	}
+}
+
+// TestWireSmallest checks that Smallest picks the encoding with the least data and that its strip decodes.
+func TestWireSmallest(t *testing.T) {
+	empty := cellsToWorld(nil, 64, 64)
+	// A checkerboard is the worst case for run length encoding.
+	checkerboard := copyWorld(empty)
+	for y := range checkerboard {
+		for x := y % 2; x < len(checkerboard[y]); x += 2 {
+			checkerboard[y][x] = 255
+		}
+	}
+	changed := copyWorld(checkerboard)
+	changed[20][20] = ^changed[20][20]
+	tests := []struct {
+		name            string
+		world, previous [][]byte
+		expected        wire.Encoding
+	}{
+		{"empty", empty, nil, wire.RunLength},
+		{"dense", checkerboard, nil, wire.BitPacked},
+		{"unchanged", checkerboard, checkerboard, wire.Delta},
+		{"one cell changed", changed, checkerboard, wire.Delta},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strip := wire.Smallest(test.world, 8, 40, test.previous)
+			for _, encoding := range encodings {
+				if encoding == wire.Delta && test.previous == nil {
+					continue
+				}
+				other := wire.Encode(test.world, 8, 40, encoding, test.previous)
+				assert(t, strip.Size() <= other.Size(), "Smallest chose %v with %v bytes but %v has %v bytes", strip.Encoding, strip.Size(), encoding, other.Size())
+			}
+			assert(t, strip.Encoding == test.expected, "Expected Smallest to choose %v, got %v", test.expected, strip.Encoding)
+
+			received := copyWorld(empty)
+			if test.previous != nil {
+				received = copyWorld(test.previous)
+			}
+			if err := wire.Decode(strip, received); err != nil {
+				t.Fatal(err)
+			}
+			for y := 8; y < 40; y++ {
+				assert(t, bytes.Equal(test.world[y], received[y]), "Row %v differs after decoding", y)
+			}
+		})
+	}
+}
+
+// TestWireMalformed checks that Decode rejects strips that do not describe a valid part of the world.
+func TestWireMalformed(t *testing.T) {
+	world := cellsToWorld(nil, 16, 16)
+	valid := func(encoding wire.Encoding) wire.Strip {
+		return wire.Encode(world, 0, 4, encoding, world)
+	}
+	tests := []struct {
+		name  string
+		strip wire.Strip
+	}{
+		{"negative height", wire.Strip{StartY: 4, Width: 16, Height: -2, Encoding: wire.Raw}},
+		{"negative width", wire.Strip{Width: -16, Height: 0, Encoding: wire.Raw}},
+		{"negative start", wire.Strip{StartY: -1, Width: 16, Height: 2, Encoding: wire.Raw}},
+		{"too tall", wire.Strip{StartY: 10, Width: 16, Height: 8, Encoding: wire.Raw, Data: make([]byte, 8*16)}},
+		{"wrong width", wire.Strip{Width: 15, Height: 1, Encoding: wire.Raw, Data: make([]byte, 15)}},
+		{"raw too short", wire.Strip{Width: 16, Height: 1, Encoding: wire.Raw, Data: make([]byte, 15)}},
+		{"raw bad cell", wire.Strip{Width: 16, Height: 1, Encoding: wire.Raw, Data: append(make([]byte, 15), 1)}},
+		{"bit packed too short", wire.Strip{Width: 16, Height: 1, Encoding: wire.BitPacked, Data: make([]byte, 1)}},
+		{"run length corrupted", wire.Strip{Width: 16, Height: 1, Encoding: wire.RunLength, Data: []byte{0x80}}},
+		{"run length too many", wire.Strip{Width: 16, Height: 1, Encoding: wire.RunLength, Data: []byte{17}}},
+		{"run length too few", wire.Strip{Width: 16, Height: 1, Encoding: wire.RunLength, Data: []byte{15}}},
+		{"run length empty run", wire.Strip{Width: 16, Height: 1, Encoding: wire.RunLength, Data: []byte{8, 0, 8}}},
+		{"delta row outside", func() wire.Strip { s := valid(wire.Delta); s.Rows = []int{4}; return s }()},
+		{"unknown encoding", func() wire.Strip { s := valid(wire.Raw); s.Encoding = 9; return s }()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := wire.Decode(test.strip, copyWorld(world))
+			assert(t, err != nil, "Expected Decode to reject the strip")
+		})
+	}
+
+	t.Run("delta onto a bad cell", func(t *testing.T) {
+		current := copyWorld(world)
+		current[1][3] = 255
+		strip := wire.Encode(current, 0, 4, wire.Delta, world)
+		received := copyWorld(world)
+		received[1][5] = 7
+		assert(t, wire.Decode(strip, received) != nil, "Expected Decode to reject a world with a cell of 7")
+	})
+}